@@ -3,7 +3,10 @@ package main
 import (
 	"hospital-api/database"
 	"hospital-api/internal/configs"
+	"hospital-api/internal/notifier"
 	"hospital-api/internal/router"
+	"hospital-api/internal/services"
+	"hospital-api/internal/validation"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +15,30 @@ import (
 func main() {
 	gin.SetMode(gin.ReleaseMode)
 
+	if err := validation.Register(); err != nil {
+		log.Fatal(err)
+	}
+
+	issuer, err := services.NewTokenIssuer(configs.Envs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hasher, err := services.NewPasswordHasher(configs.Envs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	n, err := notifier.New(configs.Envs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	oidc, err := services.NewOIDCConfig(configs.Envs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	db, err := database.NewDB()
 	if err != nil {
 		log.Fatal(err)
@@ -21,6 +48,6 @@ func main() {
 		log.Fatal(err)
 	}
 
-	r := router.SetupRouter(db)
+	r := router.SetupRouter(db, issuer, n, hasher, oidc)
 	r.Run(":" + configs.Envs.Port)
 }
@@ -20,7 +20,8 @@ func NewDB() (*gorm.DB, error) {
 		configs.Envs.DBPort,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError maps unique violations to gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
@@ -30,12 +31,55 @@ func NewDB() (*gorm.DB, error) {
 func InitSchema(db *gorm.DB) error {
 	log.Println("Initializing database schema...")
 
+	if err := scopePatientKeys(db); err != nil {
+		return err
+	}
+	migrator := db.Migrator()
+	addingMembershipSource := migrator.HasTable(&models.StaffHospitalMembership{}) &&
+		!migrator.HasColumn(&models.StaffHospitalMembership{}, "source")
+
 	// GORM's AutoMigrate
-	err := db.AutoMigrate(&models.Hospital{}, &models.UserStaff{}, &models.UserPatient{})
+	err := db.AutoMigrate(
+		&models.Hospital{},
+		&models.UserStaff{},
+		&models.UserPatient{},
+		&models.StaffInvitation{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.LoginAttempt{},
+		&models.LoginLockout{},
+		&models.StaffRecoveryCode{},
+		&models.StaffPasswordHistory{},
+		&models.PasswordResetToken{},
+		&models.StaffHospitalMembership{},
+		&models.ServiceAccount{},
+		&models.APIKey{},
+		&models.OIDCLoginState{},
+		&models.StaffSession{},
+		&models.PatientAccessLog{},
+	)
 	if err != nil {
 		return fmt.Errorf("failed to initialize schema: %v", err)
 	}
 
+	if err := backfillMemberships(db); err != nil {
+		return err
+	}
+	if addingMembershipSource {
+		if err := backfillMembershipSources(db); err != nil {
+			return err
+		}
+	}
+	if err := clearBlankPassports(db); err != nil {
+		return err
+	}
+	if err := createSearchIndexes(db); err != nil {
+		return err
+	}
+	if err := backfillPhoneticKeys(db); err != nil {
+		return err
+	}
+
 	log.Println("Database schema initialized successfully")
 
 	if err := SeedMockData(db); err != nil {
@@ -46,3 +90,167 @@ func InitSchema(db *gorm.DB) error {
 
 	return nil
 }
+
+// backfillMemberships gives staff created before hospital memberships
+// existed a membership in their home hospital with their current role.
+func backfillMemberships(db *gorm.DB) error {
+	result := db.Exec(`
+		INSERT INTO staff_hospital_memberships (staff_id, hospital_id, role, created_at, updated_at)
+		SELECT s.id, s.hospital_id, s.role, NOW(), NOW()
+		FROM user_staffs s
+		WHERE s.deleted_at IS NULL AND s.hospital_id <> ''
+		AND NOT EXISTS (
+			SELECT 1 FROM staff_hospital_memberships m
+			WHERE m.staff_id = s.id AND m.hospital_id = s.hospital_id
+		)`)
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill staff memberships: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Backfilled %d staff hospital memberships", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillMembershipSources runs once, when memberships gain their source.
+// Which memberships of SSO accounts the identity provider granted was not
+// recorded, so all active ones are taken to be its; pending ones can only
+// be admin invitations. The next login revokes those its claims no longer
+// carry.
+func backfillMembershipSources(db *gorm.DB) error {
+	result := db.Exec(`
+		UPDATE staff_hospital_memberships m SET source = ?
+		FROM user_staffs s
+		WHERE s.id = m.staff_id AND s.auth_provider = ? AND m.status = ?`,
+		models.MembershipSourceIdP, models.AuthProviderOIDC, models.MembershipActive)
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill membership sources: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Marked %d SSO staff hospital memberships as granted by the identity provider", result.RowsAffected)
+	}
+	return nil
+}
+
+// scopePatientKeys moves a patient table keyed by the national ID, with
+// identifiers unique across all hospitals, to a surrogate ID and per-hospital
+// identifiers. AutoMigrate does not change primary keys, and the old unique
+// constraints would keep hospitals from registering the same patient.
+func scopePatientKeys(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.UserPatient{}) || migrator.HasColumn(&models.UserPatient{}, "id") {
+		return nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, sql := range []string{
+			"ALTER TABLE user_patients DROP CONSTRAINT IF EXISTS uni_user_patients_patient_hn",
+			"ALTER TABLE user_patients DROP CONSTRAINT IF EXISTS user_patients_patient_hn_key",
+			"ALTER TABLE user_patients DROP CONSTRAINT IF EXISTS uni_user_patients_passport_id",
+			"ALTER TABLE user_patients DROP CONSTRAINT IF EXISTS user_patients_passport_id_key",
+			"ALTER TABLE user_patients DROP CONSTRAINT user_patients_pkey",
+			"ALTER TABLE user_patients ADD COLUMN id bigserial PRIMARY KEY",
+		} {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scope patient keys to hospitals: %v", err)
+	}
+	log.Println("Patient identifiers are now unique per hospital")
+	return nil
+}
+
+// clearBlankPassports turns empty passport IDs, which collide on the unique
+// index, into NULL.
+func clearBlankPassports(db *gorm.DB) error {
+	result := db.Exec(`UPDATE user_patients SET passport_id = NULL WHERE passport_id = ''`)
+	if result.Error != nil {
+		return fmt.Errorf("failed to clear blank passport IDs: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Cleared %d blank patient passport IDs", result.RowsAffected)
+	}
+	return nil
+}
+
+// trigramColumns are the patient columns searched with ILIKE prefix and
+// contains matches.
+var trigramColumns = []string{
+	"first_name_th", "middle_name_th", "last_name_th",
+	"first_name_en", "middle_name_en", "last_name_en",
+	"email",
+}
+
+// prefixColumns are the patient columns matched by typeahead suggestions
+// with lower(column) LIKE 'term%'.
+var prefixColumns = []string{
+	"first_name_th", "last_name_th",
+	"first_name_en", "last_name_en",
+	"patient_hn",
+}
+
+// createSearchIndexes adds pg_trgm GIN indexes so that ILIKE searches on
+// names and email do not scan the whole patient table, and btree prefix
+// indexes for suggestions. Fuzzy and free-text name search call the
+// extension's % operator and similarity(), so startup fails without it.
+func createSearchIndexes(db *gorm.DB) error {
+	for _, column := range prefixColumns {
+		sql := fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS idx_user_patients_%s_prefix ON user_patients (hospital_id, lower(%s) text_pattern_ops)",
+			column, column)
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("failed to create prefix index on %s: %v", column, err)
+		}
+	}
+
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return fmt.Errorf("failed to enable pg_trgm, which patient name search requires: %v", err)
+	}
+
+	for _, column := range trigramColumns {
+		sql := fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS idx_user_patients_%s_trgm ON user_patients USING gin (%s gin_trgm_ops)",
+			column, column)
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("failed to create search index on %s: %v", column, err)
+		}
+	}
+	return nil
+}
+
+// backfillPhoneticKeys computes the name keys of patients stored before
+// fuzzy search existed. New and updated patients get them in BeforeSave.
+func backfillPhoneticKeys(db *gorm.DB) error {
+	var patients []models.UserPatient
+	updated := 0
+	result := db.Select("id", "first_name_th", "last_name_th", "first_name_en", "last_name_en").
+		Where("first_name_th_key = '' AND last_name_th_key = '' AND first_name_en_key = '' AND last_name_en_key = ''").
+		FindInBatches(&patients, 500, func(tx *gorm.DB, batch int) error {
+			for i := range patients {
+				p := &patients[i]
+				p.SetPhoneticKeys()
+				err := db.Model(p).UpdateColumns(map[string]interface{}{
+					"first_name_th_key": p.FirstNameTHKey,
+					"last_name_th_key":  p.LastNameTHKey,
+					"first_name_en_key": p.FirstNameENKey,
+					"last_name_en_key":  p.LastNameENKey,
+				}).Error
+				if err != nil {
+					return err
+				}
+				updated++
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill patient phonetic keys: %v", result.Error)
+	}
+	if updated > 0 {
+		log.Printf("Backfilled phonetic keys of %d patients", updated)
+	}
+	return nil
+}
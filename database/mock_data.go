@@ -54,7 +54,34 @@ func clearExistingData(db *gorm.DB) error {
 	if err := db.Where("1 = 1").Delete(&models.UserPatient{}).Error; err != nil {
 		return err
 	}
-	if err := db.Where("1 = 1").Delete(&models.UserStaff{}).Error; err != nil {
+	if err := db.Where("1 = 1").Delete(&models.StaffInvitation{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("1 = 1").Delete(&models.RefreshToken{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("1 = 1").Delete(&models.StaffSession{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("1 = 1").Delete(&models.StaffRecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("1 = 1").Delete(&models.StaffPasswordHistory{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("1 = 1").Delete(&models.PasswordResetToken{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("1 = 1").Delete(&models.APIKey{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("1 = 1").Delete(&models.ServiceAccount{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("1 = 1").Delete(&models.StaffHospitalMembership{}).Error; err != nil {
+		return err
+	}
+	if err := db.Unscoped().Where("1 = 1").Delete(&models.UserStaff{}).Error; err != nil {
 		return err
 	}
 	if err := db.Where("1 = 1").Delete(&models.Hospital{}).Error; err != nil {
@@ -93,9 +120,9 @@ func seedStaff(db *gorm.DB) error {
 	}
 
 	staff := []models.UserStaff{
-		{Username: "admin1", Password: string(hashedPassword), HospitalID: "H001"},
-		{Username: "admin2", Password: string(hashedPassword), HospitalID: "H002"},
-		{Username: "admin3", Password: string(hashedPassword), HospitalID: "H003"},
+		{Username: "admin1", Password: string(hashedPassword), Role: models.RoleAdmin, HospitalID: "H001"},
+		{Username: "admin2", Password: string(hashedPassword), Role: models.RoleAdmin, HospitalID: "H002"},
+		{Username: "admin3", Password: string(hashedPassword), Role: models.RoleAdmin, HospitalID: "H003"},
 	}
 
 	for _, s := range staff {
@@ -103,7 +130,12 @@ func seedStaff(db *gorm.DB) error {
 			log.Printf("Error creating staff %s: %v", s.Username, err)
 			return err
 		}
-		log.Printf("Created staff: %s (Hospital ID: %s)", s.Username, s.HospitalID)
+		membership := models.StaffHospitalMembership{StaffID: s.ID, HospitalID: s.HospitalID, Role: s.Role}
+		if err := db.Create(&membership).Error; err != nil {
+			log.Printf("Error creating membership for staff %s: %v", s.Username, err)
+			return err
+		}
+		log.Printf("Created staff: %s (Role: %s, Hospital ID: %s)", s.Username, s.Role, s.HospitalID)
 	}
 
 	return nil
@@ -119,53 +151,55 @@ func seedPatients(db *gorm.DB) error {
 	birthDate1985, _ := time.Parse(dateFormat, "1985-06-20")
 	birthDate1992, _ := time.Parse(dateFormat, "1992-12-10")
 
+	passport := func(id string) *string { return &id }
+
 	patients := []models.UserPatient{
 		{
-			NationalID: "1234567890123", PatientHN: "HN001",
+			NationalID: "1234567890121", PatientHN: "HN001",
 			FirstNameTH: "สมชาย", LastNameTH: "ใจดี",
 			FirstNameEN: "Somchai", LastNameEN: "Jaidee",
-			DateOfBirth: birthDate1990, PassportID: "P001",
-			PhoneNumber: "081-234-5678", Email: "somchai@test.com",
+			DateOfBirth: birthDate1990, PassportID: passport("AA1000001"),
+			PhoneNumber: "0812345678", Email: "somchai@test.com",
 			Gender: "M", HospitalID: "H001",
 		},
 		{
 			NationalID: "2345678901234", PatientHN: "HN002",
 			FirstNameTH: "สมหญิง", LastNameTH: "สวยงาม",
 			FirstNameEN: "Somying", LastNameEN: "Suaynam",
-			DateOfBirth: birthDate1985, PassportID: "P002",
-			PhoneNumber: "082-345-6789", Email: "somying@test.com",
+			DateOfBirth: birthDate1985, PassportID: passport("AA1000002"),
+			PhoneNumber: "0823456789", Email: "somying@test.com",
 			Gender: "F", HospitalID: "H002",
 		},
 		{
-			NationalID: "3456789012345", PatientHN: "HN003",
+			NationalID: "3456789012347", PatientHN: "HN003",
 			FirstNameTH: "วิชัย", LastNameTH: "เก่งมาก",
 			FirstNameEN: "Wichai", LastNameEN: "Kengmak",
-			DateOfBirth: birthDate1992, PassportID: "P003",
-			PhoneNumber: "083-456-7890", Email: "wichai@test.com",
+			DateOfBirth: birthDate1992, PassportID: passport("AA1000003"),
+			PhoneNumber: "0834567890", Email: "wichai@test.com",
 			Gender: "M", HospitalID: "H003",
 		},
 		{
-			NationalID: "4567890123456", PatientHN: "HN004",
+			NationalID: "4567890123459", PatientHN: "HN004",
 			FirstNameTH: "วันทนา", LastNameTH: "รักดี",
 			FirstNameEN: "Wantana", LastNameEN: "Rakdee",
-			DateOfBirth: birthDate1985, PassportID: "P004",
-			PhoneNumber: "084-567-8901", Email: "wantana@test.com",
+			DateOfBirth: birthDate1985, PassportID: passport("AA1000004"),
+			PhoneNumber: "0845678901", Email: "wantana@test.com",
 			Gender: "F", HospitalID: "H001",
 		},
 		{
-			NationalID: "5678901234567", PatientHN: "HN005",
+			NationalID: "5678901234560", PatientHN: "HN005",
 			FirstNameTH: "ประพจน์", LastNameTH: "สมประสงค์",
 			FirstNameEN: "Prapot", LastNameEN: "Somprasong",
-			DateOfBirth: birthDate1992, PassportID: "P005",
-			PhoneNumber: "085-678-9012", Email: "prapot@test.com",
+			DateOfBirth: birthDate1992, PassportID: passport("AA1000005"),
+			PhoneNumber: "0856789012", Email: "prapot@test.com",
 			Gender: "M", HospitalID: "H002",
 		},
 		{
-			NationalID: "6789012345678", PatientHN: "HN006",
+			NationalID: "6789012345670", PatientHN: "HN006",
 			FirstNameTH: "สุภาพร", LastNameTH: "เรียนดี",
 			FirstNameEN: "Supaporn", LastNameEN: "Riandee",
-			DateOfBirth: birthDate1990, PassportID: "P006",
-			PhoneNumber: "086-789-0123", Email: "supaporn@test.com",
+			DateOfBirth: birthDate1990, PassportID: passport("AA1000006"),
+			PhoneNumber: "0867890123", Email: "supaporn@test.com",
 			Gender: "F", HospitalID: "H003",
 		},
 	}
@@ -208,7 +242,7 @@ func GetMockDataSummary(db *gorm.DB) {
 		var staff []models.UserStaff
 		db.Preload("Hospital").Find(&staff)
 		for _, s := range staff {
-			log.Printf("  - Username: %s, Hospital: %s", s.Username, s.Hospital.Name)
+			log.Printf("  - Username: %s, Role: %s, Hospital: %s", s.Username, s.Role, s.Hospital.Name)
 		}
 	}
 }
@@ -4,23 +4,70 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+// DefaultJWTSecret is the development fallback for JWT_SECRET. The token
+// issuer refuses to use it outside development.
+const DefaultJWTSecret = "not-so-secret-now-is-it?"
+
 type Config struct {
-	PublicHost             string
-	Port                   string
-	DBUser                 string
-	DBPassword             string
-	DBHost                 string
-	DBPort                 string
-	DBName                 string
-	JWTSecret              string
-	JWTExpirationInSeconds int64
-	HospitalAApiUrl        string
-	HospitalAApiTimeout    int64
-	HospitalID             int
+	AppEnv                        string
+	PublicHost                    string
+	Port                          string
+	DBUser                        string
+	DBPassword                    string
+	DBHost                        string
+	DBPort                        string
+	DBName                        string
+	JWTSecret                     string
+	JWTExpirationInSeconds        int64
+	JWTRefreshExpirationInSeconds int64
+	JWTIssuer                     string
+	JWTAudience                   string
+	JWTSigningKeys                string
+	JWTAcceptHMAC                 bool
+	TrustedProxies                []string
+	MFAIssuer                     string
+	PasswordMinLength             int64
+	PasswordRequireUpper          bool
+	PasswordRequireLower          bool
+	PasswordRequireDigit          bool
+	PasswordRequireSymbol         bool
+	PasswordHistorySize           int64
+	PasswordResetTokenMinutes     int64
+	PasswordHashAlgorithm         string
+	Argon2MemoryKB                int64
+	Argon2Iterations              int64
+	Argon2Parallelism             int64
+	BcryptCost                    int64
+	Notifier                      string
+	NotifierFilePath              string
+	NotifierWebhookURL            string
+	NotifierWebhookToken          string
+	LoginMaxFailedAttempts        int64
+	LoginLockoutMinutes           int64
+	LoginMaxFailedAttemptsPerIP   int64
+	LoginIPWindowMinutes          int64
+	OIDCIssuerURL                 string
+	OIDCAuthorizationURL          string
+	OIDCClientID                  string
+	OIDCClientSecret              string
+	OIDCRedirectURL               string
+	OIDCScopes                    []string
+	OIDCUsernameClaim             string
+	OIDCRoleClaim                 string
+	OIDCRoleMapping               []string
+	OIDCDefaultRole               string
+	OIDCHospitalClaim             string
+	OIDCHospitalMapping           []string
+	PatientSuggestTimeoutMillis   int64
+	PatientBatchMaxIdentifiers    int64
+	HospitalAApiUrl               string
+	HospitalAApiTimeout           int64
+	HospitalID                    int
 }
 
 var Envs = initConfig()
@@ -31,15 +78,57 @@ func initConfig() Config {
 	}
 
 	return Config{
-		PublicHost:             getEnv("PUBLIC_HOST", "http://localhost"),
-		Port:                   getEnv("PORT", "8002"),
-		DBUser:                 getEnv("DB_USER", "postgres"),
-		DBPassword:             getEnv("DB_PASSWORD", "postgres"),
-		DBHost:                 getEnv("DB_HOST", "localhost"),
-		DBPort:                 getEnv("DB_PORT", "5432"),
-		DBName:                 getEnv("DB_NAME", "hospital"),
-		JWTSecret:              getEnv("JWT_SECRET", "not-so-secret-now-is-it?"),
-		JWTExpirationInSeconds: getEnvAsInt("JWT_EXPIRATION_IN_SECONDS", 3600*24*7),
+		AppEnv:                        getEnv("APP_ENV", "production"),
+		PublicHost:                    getEnv("PUBLIC_HOST", "http://localhost"),
+		Port:                          getEnv("PORT", "8002"),
+		DBUser:                        getEnv("DB_USER", "postgres"),
+		DBPassword:                    getEnv("DB_PASSWORD", "postgres"),
+		DBHost:                        getEnv("DB_HOST", "localhost"),
+		DBPort:                        getEnv("DB_PORT", "5432"),
+		DBName:                        getEnv("DB_NAME", "hospital"),
+		JWTSecret:                     getEnv("JWT_SECRET", DefaultJWTSecret),
+		JWTExpirationInSeconds:        getEnvAsInt("JWT_EXPIRATION_IN_SECONDS", 60*15),
+		JWTRefreshExpirationInSeconds: getEnvAsInt("JWT_REFRESH_EXPIRATION_IN_SECONDS", 3600*24*7),
+		JWTIssuer:                     getEnv("JWT_ISSUER", "hospital-api"),
+		JWTAudience:                   getEnv("JWT_AUDIENCE", "hospital-api"),
+		JWTSigningKeys:                getEnv("JWT_SIGNING_KEYS", ""),
+		JWTAcceptHMAC:                 getEnvAsBool("JWT_ACCEPT_HMAC", false),
+		TrustedProxies:                getEnvAsList("TRUSTED_PROXIES"),
+		MFAIssuer:                     getEnv("MFA_ISSUER", "Hospital API"),
+		PasswordMinLength:             getEnvAsInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordRequireUpper:          getEnvAsBool("PASSWORD_REQUIRE_UPPER", true),
+		PasswordRequireLower:          getEnvAsBool("PASSWORD_REQUIRE_LOWER", true),
+		PasswordRequireDigit:          getEnvAsBool("PASSWORD_REQUIRE_DIGIT", true),
+		PasswordRequireSymbol:         getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordHistorySize:           getEnvAsInt("PASSWORD_HISTORY_SIZE", 5),
+		PasswordResetTokenMinutes:     getEnvAsInt("PASSWORD_RESET_TOKEN_MINUTES", 60),
+		PasswordHashAlgorithm:         getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		Argon2MemoryKB:                getEnvAsInt("ARGON2_MEMORY_KB", 64*1024),
+		Argon2Iterations:              getEnvAsInt("ARGON2_ITERATIONS", 3),
+		Argon2Parallelism:             getEnvAsInt("ARGON2_PARALLELISM", 2),
+		BcryptCost:                    getEnvAsInt("BCRYPT_COST", 10),
+		Notifier:                      getEnv("NOTIFIER", ""),
+		NotifierFilePath:              getEnv("NOTIFIER_FILE_PATH", "notifications.log"),
+		NotifierWebhookURL:            getEnv("NOTIFIER_WEBHOOK_URL", ""),
+		NotifierWebhookToken:          getEnv("NOTIFIER_WEBHOOK_TOKEN", ""),
+		LoginMaxFailedAttempts:        getEnvAsInt("LOGIN_MAX_FAILED_ATTEMPTS", 5),
+		LoginLockoutMinutes:           getEnvAsInt("LOGIN_LOCKOUT_MINUTES", 15),
+		LoginMaxFailedAttemptsPerIP:   getEnvAsInt("LOGIN_MAX_FAILED_ATTEMPTS_PER_IP", 20),
+		LoginIPWindowMinutes:          getEnvAsInt("LOGIN_IP_WINDOW_MINUTES", 15),
+		OIDCIssuerURL:                 getEnv("OIDC_ISSUER_URL", ""),
+		OIDCAuthorizationURL:          getEnv("OIDC_AUTHORIZATION_URL", ""),
+		OIDCClientID:                  getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:              getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:               getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:                    getEnvAsList("OIDC_SCOPES"),
+		OIDCUsernameClaim:             getEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+		OIDCRoleClaim:                 getEnv("OIDC_ROLE_CLAIM", "roles"),
+		OIDCRoleMapping:               getEnvAsList("OIDC_ROLE_MAPPING"),
+		OIDCDefaultRole:               getEnv("OIDC_DEFAULT_ROLE", ""),
+		OIDCHospitalClaim:             getEnv("OIDC_HOSPITAL_CLAIM", "hospital_id"),
+		OIDCHospitalMapping:           getEnvAsList("OIDC_HOSPITAL_MAPPING"),
+		PatientSuggestTimeoutMillis:   getEnvAsInt("PATIENT_SUGGEST_TIMEOUT_MS", 300),
+		PatientBatchMaxIdentifiers:    getEnvAsInt("PATIENT_BATCH_MAX_IDENTIFIERS", 100),
 		// HospitalAApiUrl:        getEnv("HOSPITAL_A_API_URL", "https://hospital-a.api.co.th"),
 		// HospitalAApiUrl:     getEnv("HOSPITAL_A_API_URL", "http://localhost:8001"),
 		// HospitalAApiTimeout: getEnvAsInt("HOSPITAL_A_API_TIMEOUT", 10),
@@ -47,6 +136,10 @@ func initConfig() Config {
 	}
 }
 
+func (c Config) IsDevelopment() bool {
+	return c.AppEnv == "development" || c.AppEnv == "dev"
+}
+
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
@@ -64,3 +157,29 @@ func getEnvAsInt(key string, fallback int64) int64 {
 	}
 	return fallback
 }
+
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fallback
+		}
+		return b
+	}
+	return fallback
+}
+
+func getEnvAsList(key string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
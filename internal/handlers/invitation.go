@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"hospital-api/internal/models"
+	"hospital-api/internal/services"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+}
+
+func NewInvitationHandler(db *gorm.DB) *InvitationHandler {
+	return &InvitationHandler{invitationService: services.NewInvitationService(db)}
+}
+
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var req models.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	hospitalID := c.GetString("hospital_id")
+	staffID := c.GetInt("staff_id")
+	ttl := time.Duration(req.ExpiresInHours) * time.Hour
+
+	code, invitation, err := h.invitationService.IssueInvitation(hospitalID, uint(staffID), req.Role, ttl)
+	if err != nil {
+		log.Printf("Invitation error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create invitation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Invitation created successfully",
+		Data: models.CreateInvitationResponse{
+			ID:        invitation.ID,
+			Code:      code,
+			Role:      invitation.Role,
+			ExpiresAt: invitation.ExpiresAt,
+		},
+	})
+}
+
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	hospitalID := c.GetString("hospital_id")
+
+	invitations, err := h.invitationService.ListInvitations(hospitalID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list invitations: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Invitations found",
+		Data: gin.H{
+			"invitations": invitations,
+			"count":       len(invitations),
+		},
+	})
+}
+
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid invitation ID",
+		})
+		return
+	}
+
+	hospitalID := c.GetString("hospital_id")
+	if err := h.invitationService.RevokeInvitation(hospitalID, uint(id)); err != nil {
+		if errors.Is(err, services.ErrInvitationNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "Invitation not found or no longer active",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to revoke invitation: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Invitation revoked",
+	})
+}
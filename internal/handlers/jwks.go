@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"hospital-api/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	issuer *services.TokenIssuer
+}
+
+func NewJWKSHandler(issuer *services.TokenIssuer) *JWKSHandler {
+	return &JWKSHandler{issuer: issuer}
+}
+
+// GetJWKS serves the public signing keys as a plain RFC 7517 document, not
+// wrapped in models.APIResponse, so standard JWT libraries can consume it.
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.issuer.JWKS())
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"hospital-api/internal/models"
+	"hospital-api/internal/services"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MFAHandler struct {
+	mfaService        *services.MFAService
+	tokenService      *services.TokenService
+	membershipService *services.MembershipService
+}
+
+func NewMFAHandler(db *gorm.DB, issuer *services.TokenIssuer) *MFAHandler {
+	return &MFAHandler{
+		mfaService:        services.NewMFAService(db),
+		tokenService:      services.NewTokenService(db, issuer),
+		membershipService: services.NewMembershipService(db),
+	}
+}
+
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	staffID := c.GetInt("staff_id")
+
+	response, err := h.mfaService.BeginEnrollment(uint(staffID))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Scan the QR code and confirm with a code from your authenticator app",
+		Data:    response,
+	})
+}
+
+// Confirm activates MFA. When called with the enrolment token from a login
+// that was blocked on the hospital's MFA policy, it also finishes that login.
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	staffID := c.GetInt("staff_id")
+	staff, codes, err := h.mfaService.ConfirmEnrollment(uint(staffID), req.Code)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	response := models.MFAConfirmResponse{RecoveryCodes: codes}
+
+	claims := c.MustGet("claims").(*models.JWTClaims)
+	if claims.Purpose == models.TokenPurposeMFAEnroll {
+		if err := h.tokenService.RevokeAccessToken(claims); err != nil {
+			log.Printf("Failed to revoke MFA enrolment token: %v", err)
+		}
+
+		// Finish the login in the hospital it was started for.
+		staff, err = h.membershipService.ScopeToHospital(staff, claims.HospitalID)
+		if err == nil && staff.MustChangePassword {
+			response.Tokens, err = h.tokenService.IssueChallenge(staff, models.TokenPurposePasswordChange)
+		} else if err == nil {
+			response.Tokens, err = h.tokenService.IssueTokenPair(staff, clientInfo(c))
+		}
+		if err != nil {
+			log.Printf("JWT error: %v", err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "MFA enabled but failed to generate token, please log in again",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "MFA enabled. Store the recovery codes somewhere safe",
+		Data:    response,
+	})
+}
+
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	staffID := c.GetInt("staff_id")
+	if err := h.mfaService.Disable(uint(staffID), req.Code, c.ClientIP()); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "MFA disabled",
+	})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	staffID := c.GetInt("staff_id")
+	codes, err := h.mfaService.RegenerateRecoveryCodes(uint(staffID), req.Code, c.ClientIP())
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Recovery codes regenerated",
+		Data:    models.MFAConfirmResponse{RecoveryCodes: codes},
+	})
+}
+
+func (h *MFAHandler) SetHospitalPolicy(c *gin.Context) {
+	var req models.HospitalMFAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	hospitalID := c.GetString("hospital_id")
+	if err := h.mfaService.SetHospitalRequirement(hospitalID, *req.RequireMFA); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	log.Printf("SECURITY: admin %d set require_mfa=%t for hospital %s", c.GetInt("staff_id"), *req.RequireMFA, hospitalID)
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Hospital MFA policy updated",
+		Data:    gin.H{"hospital_id": hospitalID, "require_mfa": *req.RequireMFA},
+	})
+}
+
+func (h *MFAHandler) respondError(c *gin.Context, err error) {
+	var blocked *services.LoginBlockedError
+	if errors.As(err, &blocked) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter().Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.APIResponse{
+			Success: false,
+			Error:   "Too many failed attempts, please try again later",
+		})
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrMFAAlreadyEnabled),
+		errors.Is(err, services.ErrMFANotEnabled),
+		errors.Is(err, services.ErrMFANotEnrolled):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrMFARequiredByHospital):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrStaffNotFound), errors.Is(err, services.ErrHospitalNotFound):
+		status = http.StatusNotFound
+	}
+
+	if status == http.StatusInternalServerError {
+		log.Printf("MFA error: %v", err)
+		c.JSON(status, models.APIResponse{
+			Success: false,
+			Error:   "MFA operation failed",
+		})
+		return
+	}
+
+	c.JSON(status, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"hospital-api/internal/models"
+	"hospital-api/internal/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OIDCHandler struct {
+	oidcService  *services.OIDCService
+	tokenService *services.TokenService
+}
+
+func NewOIDCHandler(db *gorm.DB, issuer *services.TokenIssuer, cfg *services.OIDCConfig) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:  services.NewOIDCService(db, cfg),
+		tokenService: services.NewTokenService(db, issuer),
+	}
+}
+
+// Login redirects the browser to the identity provider. Clients asking for
+// JSON get the authorization URL instead. The optional hospital_id query
+// parameter picks the hospital the token will be scoped to.
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, err := h.oidcService.AuthorizationURL(c.Request.Context(), c.Query("hospital_id"))
+	if err != nil {
+		log.Printf("OIDC login error: %v", err)
+		c.JSON(http.StatusBadGateway, models.APIResponse{
+			Success: false,
+			Error:   "Identity provider is unavailable",
+		})
+		return
+	}
+
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "Continue at the identity provider",
+			Data:    gin.H{"authorization_url": authURL},
+		})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback is the redirect URI registered at the identity provider. It
+// finishes the login and returns our own token pair.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if idpError := c.Query("error"); idpError != "" {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Identity provider rejected the login: " + idpError,
+		})
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "code and state are required",
+		})
+		return
+	}
+
+	staff, err := h.oidcService.Callback(c.Request.Context(), code, state)
+	if err != nil {
+		log.Printf("OIDC callback error: %v", err)
+		status, message := http.StatusUnauthorized, "Single sign-on failed"
+		switch {
+		case errors.Is(err, services.ErrOIDCStateInvalid):
+			status, message = http.StatusBadRequest, "Login session expired, please start again"
+		case errors.Is(err, services.ErrOIDCNoRole),
+			errors.Is(err, services.ErrOIDCNoHospital),
+			errors.Is(err, services.ErrNotHospitalMember),
+			errors.Is(err, services.ErrStaffInactive):
+			status, message = http.StatusForbidden, err.Error()
+		case errors.Is(err, services.ErrUsernameTaken):
+			status, message = http.StatusConflict, "A local account with this username already exists"
+		case !errors.Is(err, services.ErrOIDCLoginFailed):
+			status, message = http.StatusInternalServerError, "Failed to complete single sign-on"
+		}
+		c.JSON(status, models.APIResponse{
+			Success: false,
+			Error:   message,
+		})
+		return
+	}
+
+	response, err := h.tokenService.IssueTokenPair(staff, clientInfo(c))
+	if err != nil {
+		log.Printf("JWT error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Login successful",
+		Data:    response,
+	})
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"hospital-api/internal/models"
 	"hospital-api/internal/services"
+	"hospital-api/internal/validation"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -27,6 +30,11 @@ func (h *PatientHandler) SearchPatient(c *gin.Context) {
 		return
 	}
 
+	view, ok := h.presenter(c)
+	if !ok {
+		return
+	}
+
 	// get id parameter in URL path
 	id := c.Param("id")
 	if id == "" {
@@ -58,11 +66,40 @@ func (h *PatientHandler) SearchPatient(c *gin.Context) {
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "Patient found",
-		Data:    patient,
+		Data:    view.Patient(patient),
 	})
 }
 
+// SearchPatients searches with criteria from the query string.
 func (h *PatientHandler) SearchPatients(c *gin.Context) {
+	var criteria models.PatientSearchCriteria
+	if err := c.ShouldBindQuery(&criteria); err != nil {
+		respondInvalidInput(c, err)
+		return
+	}
+	if modes := c.QueryMap("match"); len(modes) > 0 {
+		criteria.Match = make(map[string]models.MatchMode, len(modes))
+		for field, mode := range modes {
+			criteria.Match[field] = models.MatchMode(mode)
+		}
+	}
+
+	h.searchPatients(c, &criteria)
+}
+
+// SearchPatientsByBody searches with criteria from a JSON body, for
+// clients whose criteria do not fit a query string.
+func (h *PatientHandler) SearchPatientsByBody(c *gin.Context) {
+	var criteria models.PatientSearchCriteria
+	if err := c.ShouldBindJSON(&criteria); err != nil {
+		respondInvalidInput(c, err)
+		return
+	}
+
+	h.searchPatients(c, &criteria)
+}
+
+func (h *PatientHandler) searchPatients(c *gin.Context, criteria *models.PatientSearchCriteria) {
 	hospitalID, exists := c.Get("hospital_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, models.APIResponse{
@@ -72,52 +109,360 @@ func (h *PatientHandler) SearchPatients(c *gin.Context) {
 		return
 	}
 
-	var req models.PatientSearchRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.APIResponse{
+	view, ok := h.presenter(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.patientService.SearchPatients(hospitalID.(string), criteria, view)
+	if err != nil {
+		var fieldErrs validation.Errors
+		if errors.As(err, &fieldErrs) {
+			respondPatientError(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to search patients: " + err.Error(),
+		})
+		return
+	}
+
+	// Only an empty first page means nothing matched; later pages may run
+	// dry while paging.
+	if result.Count == 0 && criteria.Offset == 0 && criteria.Cursor == "" {
+		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
-			Error:   "Invalid input: " + err.Error(),
+			Error:   "No patients found matching the criteria",
 		})
 		return
 	}
 
-	searchParams := map[string]string{
-		"national_id":   req.NationalID,
-		"patient_hn":    req.PatientHN,
-		"first_name_th": req.FirstNameTH,
-		"last_name_th":  req.LastNameTH,
-		"first_name_en": req.FirstNameEN,
-		"last_name_en":  req.LastNameEN,
-		"passport_id":   req.PassportID,
-		"phone_number":  req.PhoneNumber,
-		"email":         req.Email,
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Patients found",
+		Data:    result,
+	})
+}
+
+// SuggestPatients returns typeahead suggestions for the registration UI.
+func (h *PatientHandler) SuggestPatients(c *gin.Context) {
+	var req models.PatientSuggestRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondInvalidInput(c, err)
+		return
+	}
+
+	view, ok := h.presenter(c)
+	if !ok {
+		return
 	}
 
-	patients, err := h.patientService.SearchPatients(hospitalID.(string), searchParams)
+	suggestions, err := h.patientService.SuggestPatients(c.Request.Context(), c.GetString("hospital_id"), req.Q, req.Limit, view)
 	if err != nil {
+		var fieldErrs validation.Errors
+		if errors.As(err, &fieldErrs) {
+			respondPatientError(c, err)
+			return
+		}
+		if errors.Is(err, services.ErrSuggestTimeout) {
+			c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+				Success: false,
+				Error:   "Suggestions are taking too long, please keep typing",
+			})
+			return
+		}
+		log.Printf("Patient suggest error: %v", err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "Failed to search patients: " + err.Error(),
+			Error:   "Failed to suggest patients",
 		})
 		return
 	}
 
-	if len(patients) == 0 {
-		c.JSON(http.StatusNotFound, models.APIResponse{
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Patient suggestions",
+		Data:    suggestions,
+	})
+}
+
+// LookupPatients resolves a list of identifiers in one request, for
+// integrations that would otherwise call SearchPatient in a loop.
+func (h *PatientHandler) LookupPatients(c *gin.Context) {
+	var req models.PatientBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidInput(c, err)
+		return
+	}
+	view, ok := h.presenter(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.patientService.LookupPatients(c.GetString("hospital_id"), req.Identifiers, req.Type, view)
+	if err != nil {
+		var fieldErrs validation.Errors
+		if errors.As(err, &fieldErrs) {
+			respondPatientError(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "No patients found matching the criteria",
+			Error:   "Failed to look up patients: " + err.Error(),
 		})
 		return
 	}
 
-	responseData := gin.H{
-		"patients": patients,
-		"count":    len(patients),
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Patients looked up",
+		Data:    result,
+	})
+}
+
+// CreatePatient registers a patient in the caller's hospital.
+func (h *PatientHandler) CreatePatient(c *gin.Context) {
+	var req models.CreatePatientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidInput(c, err)
+		return
+	}
+	view, ok := h.presenter(c)
+	if !ok {
+		return
+	}
+
+	patient, err := h.patientService.CreatePatient(c.GetString("hospital_id"), uint(c.GetInt("staff_id")), &req)
+	if err != nil {
+		respondPatientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Patient created",
+		Data:    view.Patient(patient),
+	})
+}
+
+// ReplacePatient overwrites a patient record; omitted optional fields are
+// cleared.
+func (h *PatientHandler) ReplacePatient(c *gin.Context) {
+	var req models.PatientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidInput(c, err)
+		return
+	}
+	view, ok := h.presenter(c)
+	if !ok {
+		return
+	}
+
+	id, ok := patientIDParam(c)
+	if !ok {
+		return
+	}
+
+	patient, err := h.patientService.ReplacePatient(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id")), &req)
+	if err != nil {
+		respondPatientError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
-		Message: "Patients found",
-		Data:    responseData,
+		Message: "Patient updated",
+		Data:    view.Patient(patient),
 	})
 }
+
+func (h *PatientHandler) PatchPatient(c *gin.Context) {
+	var req models.PatchPatientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidInput(c, err)
+		return
+	}
+	view, ok := h.presenter(c)
+	if !ok {
+		return
+	}
+
+	id, ok := patientIDParam(c)
+	if !ok {
+		return
+	}
+
+	patient, err := h.patientService.PatchPatient(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id")), &req)
+	if err != nil {
+		respondPatientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Patient updated",
+		Data:    view.Patient(patient),
+	})
+}
+
+func (h *PatientHandler) DeletePatient(c *gin.Context) {
+	id, ok := patientIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.patientService.DeletePatient(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id"))); err != nil {
+		respondPatientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Patient deleted",
+	})
+}
+
+// UnmaskPatient reveals a patient's masked personal data to the caller
+// once the reason has been recorded in the patient access log.
+func (h *PatientHandler) UnmaskPatient(c *gin.Context) {
+	id, ok := patientIDParam(c)
+	if !ok {
+		return
+	}
+	var req models.UnmaskPatientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidInput(c, err)
+		return
+	}
+
+	role, _ := c.Get("role")
+	staffRole, _ := role.(models.Role)
+	patient, err := h.patientService.UnmaskPatient(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id")),
+		staffRole, clientInfo(c), &req, c.Query("fields"))
+	if err != nil {
+		respondPatientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Patient unmasked; this access has been recorded",
+		Data:    patient,
+	})
+}
+
+// ListPatientAccessLogs returns who unmasked a patient's data and why.
+func (h *PatientHandler) ListPatientAccessLogs(c *gin.Context) {
+	id, ok := patientIDParam(c)
+	if !ok {
+		return
+	}
+
+	logs, err := h.patientService.ListPatientAccessLogs(c.GetString("hospital_id"), id)
+	if err != nil {
+		respondPatientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Patient access log",
+		Data:    logs,
+	})
+}
+
+func patientIDParam(c *gin.Context) (uint, bool) {
+	return uintParam(c, "id", "Invalid patient ID")
+}
+
+// presenter returns the response shaping for the caller's role and the
+// fields= query parameter, answering 400 for unknown fields.
+func (h *PatientHandler) presenter(c *gin.Context) (*services.PatientPresenter, bool) {
+	role, _ := c.Get("role")
+	staffRole, _ := role.(models.Role)
+	_, isService := c.Get("service_account_id")
+	view, err := services.NewPatientPresenter(staffRole, isService, c.Query("fields"))
+	if err != nil {
+		respondPatientError(c, err)
+		return nil, false
+	}
+	return view, true
+}
+
+// SetHNFormat sets the HN pattern enforced when patients of the admin's
+// hospital are registered or updated.
+func (h *PatientHandler) SetHNFormat(c *gin.Context) {
+	var req models.HospitalHNFormatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalidInput(c, err)
+		return
+	}
+
+	hospitalID := c.GetString("hospital_id")
+	if err := h.patientService.SetHNPattern(hospitalID, req.Pattern); err != nil {
+		respondPatientError(c, err)
+		return
+	}
+
+	log.Printf("Admin %d set the HN pattern of hospital %s to %q", c.GetInt("staff_id"), hospitalID, req.Pattern)
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Hospital HN format updated",
+		Data:    gin.H{"hospital_id": hospitalID, "hn_pattern": req.Pattern},
+	})
+}
+
+// respondInvalidInput answers 400 for a request that failed to bind, with
+// per-field details when validation rules were violated.
+func respondInvalidInput(c *gin.Context, err error) {
+	if fields := validation.FieldErrors(err); fields != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+			Data:    gin.H{"fields": fields},
+		})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, models.APIResponse{
+		Success: false,
+		Error:   "Invalid input: " + err.Error(),
+	})
+}
+
+func respondPatientError(c *gin.Context, err error) {
+	var fieldErrs validation.Errors
+	switch {
+	case errors.As(err, &fieldErrs):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input",
+			Data:    gin.H{"fields": fieldErrs},
+		})
+	case errors.Is(err, services.ErrPatientNotFound):
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Patient not found",
+		})
+	case errors.Is(err, services.ErrHospitalNotFound):
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Hospital not found",
+		})
+	case errors.Is(err, services.ErrDuplicateNationalID),
+		errors.Is(err, services.ErrDuplicateHN),
+		errors.Is(err, services.ErrDuplicatePassport),
+		errors.Is(err, services.ErrPatientExists):
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	default:
+		log.Printf("Patient error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to save patient",
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"hospital-api/internal/services"
+	"hospital-api/internal/validation"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondPatientError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"duplicate national ID", services.ErrDuplicateNationalID, http.StatusConflict},
+		{"duplicate HN", services.ErrDuplicateHN, http.StatusConflict},
+		{"duplicate passport", services.ErrDuplicatePassport, http.StatusConflict},
+		{"unattributed unique violation", services.ErrPatientExists, http.StatusConflict},
+		{"wrapped duplicate", fmt.Errorf("save: %w", services.ErrDuplicateHN), http.StatusConflict},
+		{"not found", services.ErrPatientNotFound, http.StatusNotFound},
+		{"hospital not found", services.ErrHospitalNotFound, http.StatusNotFound},
+		{"invalid field", validation.Errors{{Field: "patient_hn", Message: "is invalid"}}, http.StatusBadRequest},
+		{"database failure", errors.New("failed to save patient: connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			respondPatientError(c, tt.err)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"hospital-api/internal/models"
+	"hospital-api/internal/services"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountService *services.ServiceAccountService
+}
+
+func NewServiceAccountHandler(db *gorm.DB) *ServiceAccountHandler {
+	return &ServiceAccountHandler{serviceAccountService: services.NewServiceAccountService(db)}
+}
+
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	account, err := h.serviceAccountService.CreateServiceAccount(c.GetString("hospital_id"), uint(c.GetInt("staff_id")), &req)
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Service account created",
+		Data:    account,
+	})
+}
+
+func (h *ServiceAccountHandler) ListServiceAccounts(c *gin.Context) {
+	accounts, err := h.serviceAccountService.ListServiceAccounts(c.GetString("hospital_id"))
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Service accounts found",
+		Data: gin.H{
+			"service_accounts": accounts,
+			"count":            len(accounts),
+		},
+	})
+}
+
+// DeactivateServiceAccount disables the account and revokes all its keys.
+func (h *ServiceAccountHandler) DeactivateServiceAccount(c *gin.Context) {
+	id, ok := uintParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	if err := h.serviceAccountService.DeactivateServiceAccount(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id"))); err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Service account deactivated",
+	})
+}
+
+func (h *ServiceAccountHandler) CreateAPIKey(c *gin.Context) {
+	id, ok := uintParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	key, record, err := h.serviceAccountService.IssueAPIKey(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id")), req.Scopes, ttl)
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "API key created. Store it now, it will not be shown again",
+		Data:    models.CreateAPIKeyResponse{Key: key, APIKey: record},
+	})
+}
+
+func (h *ServiceAccountHandler) ListAPIKeys(c *gin.Context) {
+	id, ok := uintParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	keys, err := h.serviceAccountService.ListAPIKeys(c.GetString("hospital_id"), id)
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "API keys found",
+		Data: gin.H{
+			"api_keys": keys,
+			"count":    len(keys),
+		},
+	})
+}
+
+func (h *ServiceAccountHandler) RotateAPIKey(c *gin.Context) {
+	id, ok := uintParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+	keyID, ok := uintParam(c, "key_id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	var req models.RotateAPIKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid input: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	grace := time.Duration(req.GraceMinutes) * time.Minute
+	key, record, err := h.serviceAccountService.RotateAPIKey(c.GetString("hospital_id"), id, keyID, uint(c.GetInt("staff_id")), grace)
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "API key rotated. Store the new key now, it will not be shown again",
+		Data:    models.CreateAPIKeyResponse{Key: key, APIKey: record},
+	})
+}
+
+func (h *ServiceAccountHandler) RevokeAPIKey(c *gin.Context) {
+	id, ok := uintParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+	keyID, ok := uintParam(c, "key_id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	if err := h.serviceAccountService.RevokeAPIKey(c.GetString("hospital_id"), id, keyID, uint(c.GetInt("staff_id"))); err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "API key revoked",
+	})
+}
+
+// uintParam parses a numeric path parameter and answers 400 with message
+// when it is not one.
+func uintParam(c *gin.Context, name, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   message,
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondServiceAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrServiceAccountNotFound):
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Service account not found",
+		})
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "API key not found or no longer active",
+		})
+	case errors.Is(err, services.ErrServiceAccountExists):
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	default:
+		log.Printf("Service account error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to process service account request",
+		})
+	}
+}
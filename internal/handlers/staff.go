@@ -1,21 +1,37 @@
 package handlers
 
 import (
+	"errors"
 	"hospital-api/internal/models"
+	"hospital-api/internal/notifier"
 	"hospital-api/internal/services"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type StaffHandler struct {
-	staffService *services.StaffService
+	staffService      *services.StaffService
+	tokenService      *services.TokenService
+	mfaService        *services.MFAService
+	membershipService *services.MembershipService
+	sessionService    *services.SessionService
+	notifier          notifier.Notifier
 }
 
-func NewStaffHandler(db *gorm.DB) *StaffHandler {
-	return &StaffHandler{staffService: services.NewStaffService(db)}
+func NewStaffHandler(db *gorm.DB, issuer *services.TokenIssuer, n notifier.Notifier, hasher services.PasswordHasher) *StaffHandler {
+	return &StaffHandler{
+		staffService:      services.NewStaffService(db, hasher),
+		tokenService:      services.NewTokenService(db, issuer),
+		mfaService:        services.NewMFAService(db),
+		membershipService: services.NewMembershipService(db),
+		sessionService:    services.NewSessionService(db),
+		notifier:          n,
+	}
 }
 
 func (h *StaffHandler) CreateStaff(c *gin.Context) {
@@ -29,10 +45,32 @@ func (h *StaffHandler) CreateStaff(c *gin.Context) {
 		return
 	}
 
-	staff, err := h.staffService.CreateStaffByRequest(&req)
+	var staff *models.UserStaff
+	var err error
+	role, _ := c.Get("role")
+	switch {
+	case role == models.RoleAdmin:
+		hospitalID := c.GetString("hospital_id")
+		staff, err = h.staffService.CreateStaffByAdmin(hospitalID, &req)
+	case req.InvitationCode != "":
+		staff, err = h.staffService.CreateStaffByInvitation(&req)
+	default:
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Staff creation requires a hospital admin token or an invitation code",
+		})
+		return
+	}
+
 	if err != nil {
 		log.Printf("Service error: %v", err)
-		c.JSON(http.StatusBadRequest, models.APIResponse{
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvalidInvitation) {
+			status = http.StatusForbidden
+		} else if errors.Is(err, services.ErrUsernameTaken) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, models.APIResponse{
 			Success: false,
 			Error:   "Failed to create staff: " + err.Error(),
 		})
@@ -61,7 +99,24 @@ func (h *StaffHandler) Login(c *gin.Context) {
 		return
 	}
 
-	staff, err := h.staffService.LoginByRequest(&req)
+	staff, err := h.staffService.LoginByRequest(&req, c.ClientIP())
+	var blocked *services.LoginBlockedError
+	if errors.As(err, &blocked) {
+		log.Printf("Login blocked for user '%s': %v", req.Username, err)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter().Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.APIResponse{
+			Success: false,
+			Error:   "Too many failed login attempts, please try again later",
+		})
+		return
+	}
+	if errors.Is(err, services.ErrStaffInactive) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Account is deactivated",
+		})
+		return
+	}
 	if err != nil {
 		log.Printf("Login service error for user '%s': %v", req.Username, err)
 		c.JSON(http.StatusUnauthorized, models.APIResponse{
@@ -71,7 +126,42 @@ func (h *StaffHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := services.GenerateJWT(int(staff.ID), staff.HospitalID)
+	scoped, ok := h.scopeToHospital(c, staff, req.HospitalID)
+	if !ok {
+		return
+	}
+
+	h.completeLogin(c, scoped, false)
+}
+
+// completeLogin issues the full token pair, or a challenge token when the
+// staff member still has to pass (or enrol in) MFA or change a password set
+// by an admin.
+func (h *StaffHandler) completeLogin(c *gin.Context, staff *models.UserStaff, mfaVerified bool) {
+	purpose := ""
+	if !mfaVerified {
+		var err error
+		purpose, err = h.mfaService.LoginChallenge(staff)
+		if err != nil {
+			log.Printf("MFA check error: %v", err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to complete login",
+			})
+			return
+		}
+	}
+	if purpose == "" && staff.MustChangePassword {
+		purpose = models.TokenPurposePasswordChange
+	}
+
+	var response *models.LoginResponse
+	var err error
+	if purpose != "" {
+		response, err = h.tokenService.IssueChallenge(staff, purpose)
+	} else {
+		response, err = h.tokenService.IssueTokenPair(staff, clientInfo(c))
+	}
 	if err != nil {
 		log.Printf("JWT error: %v", err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -81,10 +171,807 @@ func (h *StaffHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response := models.LoginResponse{Token: token}
+	message := "Login successful"
+	switch purpose {
+	case models.TokenPurposeMFA:
+		message = "MFA code required"
+	case models.TokenPurposeMFAEnroll:
+		message = "MFA enrolment required"
+	case models.TokenPurposePasswordChange:
+		message = "Password change required"
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    response,
+	})
+}
+
+// LoginMFA exchanges the challenge token from Login and a TOTP or recovery
+// code for a full token pair.
+func (h *StaffHandler) LoginMFA(c *gin.Context) {
+	var req models.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	claims, err := h.tokenService.ValidateChallengeToken(req.MFAToken, models.TokenPurposeMFA)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired MFA token",
+		})
+		return
+	}
+
+	staff, err := h.mfaService.VerifyLogin(uint(claims.StaffID), req.Code, c.ClientIP())
+	var blocked *services.LoginBlockedError
+	if errors.As(err, &blocked) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter().Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.APIResponse{
+			Success: false,
+			Error:   "Too many failed login attempts, please try again later",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("MFA login error for staff %d: %v", claims.StaffID, err)
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid MFA code",
+		})
+		return
+	}
+
+	// The challenge token is single-use.
+	if err := h.tokenService.RevokeAccessToken(claims); err != nil {
+		log.Printf("Failed to revoke MFA token: %v", err)
+	}
+
+	scoped, ok := h.scopeToHospital(c, staff, claims.HospitalID)
+	if !ok {
+		return
+	}
+
+	h.completeLogin(c, scoped, true)
+}
+
+func (h *StaffHandler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	response, err := h.tokenService.Refresh(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Error:   "Invalid or expired refresh token",
+			})
+			return
+		}
+		log.Printf("Refresh error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to refresh token",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
-		Message: "Login successful",
+		Message: "Token refreshed",
 		Data:    response,
 	})
 }
+
+func (h *StaffHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid input: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	claims := c.MustGet("claims").(*models.JWTClaims)
+	if err := h.tokenService.Logout(claims, req.RefreshToken); err != nil {
+		log.Printf("Logout error for staff %d: %v", claims.StaffID, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to logout",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Logout successful",
+	})
+}
+
+func (h *StaffHandler) UnlockStaff(c *gin.Context) {
+	id, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	hospitalID := c.GetString("hospital_id")
+	adminID := c.GetInt("staff_id")
+
+	staff, err := h.staffService.UnlockStaff(hospitalID, id, uint(adminID))
+	if err != nil {
+		if errors.Is(err, services.ErrStaffNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "Staff not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to unlock staff: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Staff unlocked",
+		Data:    staff,
+	})
+}
+
+// ChangePassword also accepts the password-change token handed out by Login
+// when an admin-set password has to be replaced. Every session of the staff
+// member is ended and a fresh token pair is returned.
+func (h *StaffHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	claims := c.MustGet("claims").(*models.JWTClaims)
+	staffID := uint(claims.StaffID)
+
+	if err := h.staffService.ChangePassword(staffID, req.OldPassword, req.NewPassword, c.ClientIP()); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	h.endSessions(staffID, claims)
+
+	staff, err := h.staffService.GetStaffByID(staffID)
+	if err == nil {
+		staff, err = h.membershipService.ScopeToHospital(staff, claims.HospitalID)
+	}
+	var response *models.LoginResponse
+	if err == nil {
+		response, err = h.tokenService.IssueTokenPair(staff, clientInfo(c))
+	}
+	if err != nil {
+		log.Printf("JWT error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Password changed but failed to generate token, please log in again",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password changed successfully",
+		Data:    response,
+	})
+}
+
+// RequestPasswordReset lets a hospital admin send a one-time reset token to
+// a staff member through the configured notifier.
+func (h *StaffHandler) RequestPasswordReset(c *gin.Context) {
+	id, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	hospitalID := c.GetString("hospital_id")
+	adminID := c.GetInt("staff_id")
+
+	token, staff, err := h.staffService.CreatePasswordReset(hospitalID, id, uint(adminID))
+	if err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	err = h.notifier.Send(notifier.Message{
+		Recipient: staff.Username,
+		Subject:   "Password reset",
+		Body: "A hospital administrator started a password reset for your account.\n" +
+			"Reset token: " + token + "\n" +
+			"Use it with POST /api/v1/staff/password/reset before it expires.",
+	})
+	if err != nil {
+		log.Printf("Failed to deliver password reset for staff %d: %v", staff.ID, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to deliver password reset",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password reset sent",
+	})
+}
+
+func (h *StaffHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	staff, err := h.staffService.ResetPassword(req.Token, req.NewPassword)
+	if err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	if err := h.tokenService.RevokeAllRefreshTokens(staff.ID); err != nil {
+		log.Printf("Failed to revoke sessions for staff %d: %v", staff.ID, err)
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password reset successfully, please log in",
+	})
+}
+
+// SwitchHospital re-issues the token pair for another hospital the staff
+// member belongs to. The current access token, and the refresh token family
+// when one is given, are revoked. The new session goes through the same MFA
+// and forced password change rules as a login.
+func (h *StaffHandler) SwitchHospital(c *gin.Context) {
+	var req models.SwitchHospitalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	claims := c.MustGet("claims").(*models.JWTClaims)
+	staff, err := h.staffService.GetStaffByID(uint(claims.StaffID))
+	if err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	scoped, ok := h.scopeToHospital(c, staff, req.HospitalID)
+	if !ok {
+		return
+	}
+
+	if err := h.tokenService.Logout(claims, req.RefreshToken); err != nil {
+		log.Printf("Failed to end previous session for staff %d: %v", staff.ID, err)
+	}
+
+	h.completeLogin(c, scoped, false)
+}
+
+// MyHospitals lists the hospitals the caller can switch to.
+func (h *StaffHandler) MyHospitals(c *gin.Context) {
+	memberships, err := h.membershipService.ListMemberships(uint(c.GetInt("staff_id")))
+	if err != nil {
+		log.Printf("List memberships error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list hospitals",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Hospitals retrieved",
+		Data:    memberships,
+	})
+}
+
+// AcceptMembership activates a membership another hospital's admin added
+// the caller to.
+func (h *StaffHandler) AcceptMembership(c *gin.Context) {
+	membership, err := h.membershipService.AcceptMembership(uint(c.GetInt("staff_id")), c.Param("hospital_id"))
+	if err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Membership accepted",
+		Data:    membership,
+	})
+}
+
+// DeclineMembership turns down a pending membership.
+func (h *StaffHandler) DeclineMembership(c *gin.Context) {
+	if err := h.membershipService.DeclineMembership(uint(c.GetInt("staff_id")), c.Param("hospital_id")); err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Membership declined",
+	})
+}
+
+// scopeToHospital answers 403 when the staff member does not belong to
+// hospitalID.
+func (h *StaffHandler) scopeToHospital(c *gin.Context, staff *models.UserStaff, hospitalID string) (*models.UserStaff, bool) {
+	scoped, err := h.membershipService.ScopeToHospital(staff, hospitalID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotHospitalMember) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Not a member of the requested hospital",
+			})
+			return nil, false
+		}
+		log.Printf("Membership error for staff %d: %v", staff.ID, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to resolve hospital membership",
+		})
+		return nil, false
+	}
+	return scoped, true
+}
+
+// endSessions revokes the current access token and every refresh token.
+func (h *StaffHandler) endSessions(staffID uint, claims *models.JWTClaims) {
+	if err := h.tokenService.RevokeAccessToken(claims); err != nil {
+		log.Printf("Failed to revoke access token for staff %d: %v", staffID, err)
+	}
+	if err := h.tokenService.RevokeAllRefreshTokens(staffID); err != nil {
+		log.Printf("Failed to revoke sessions for staff %d: %v", staffID, err)
+	}
+}
+
+func respondPasswordError(c *gin.Context, err error) {
+	var policyErr *services.PasswordPolicyError
+	var blocked *services.LoginBlockedError
+	switch {
+	case errors.As(err, &blocked):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter().Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.APIResponse{
+			Success: false,
+			Error:   "Too many failed attempts, please try again later",
+		})
+	case errors.As(err, &policyErr):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   policyErr.Error(),
+			Data:    gin.H{"violations": policyErr.Violations},
+		})
+	case errors.Is(err, services.ErrPasswordReused):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidCurrentPassword):
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidResetToken),
+		errors.Is(err, services.ErrExternalAccount):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	default:
+		log.Printf("Password error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update password",
+		})
+	}
+}
+
+// ListStaff lists the staff of the admin's hospital. Supports page,
+// page_size, username (substring), role and active query parameters.
+func (h *StaffHandler) ListStaff(c *gin.Context) {
+	var query models.StaffListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid query: " + err.Error(),
+		})
+		return
+	}
+
+	staff, total, err := h.staffService.ListStaff(c.GetString("hospital_id"), &query)
+	if err != nil {
+		log.Printf("List staff error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list staff",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Staff retrieved",
+		Data: models.StaffListResponse{
+			Staff:    staff,
+			Count:    len(staff),
+			Total:    total,
+			Page:     query.Page,
+			PageSize: query.PageSize,
+		},
+	})
+}
+
+func (h *StaffHandler) GetStaff(c *gin.Context) {
+	id, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	staff, err := h.staffService.GetStaffInHospital(c.GetString("hospital_id"), id)
+	if err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Staff found",
+		Data:    staff,
+	})
+}
+
+func (h *StaffHandler) UpdateStaff(c *gin.Context) {
+	id, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	staff, err := h.staffService.UpdateStaff(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id")), &req)
+	if err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Staff updated",
+		Data:    staff,
+	})
+}
+
+// DeactivateStaff blocks login and ends every session of the staff member.
+func (h *StaffHandler) DeactivateStaff(c *gin.Context) {
+	h.setStaffActive(c, false)
+}
+
+func (h *StaffHandler) ReactivateStaff(c *gin.Context) {
+	h.setStaffActive(c, true)
+}
+
+func (h *StaffHandler) setStaffActive(c *gin.Context, active bool) {
+	id, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	staff, err := h.staffService.SetStaffActive(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id")), active)
+	if err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	message := "Staff reactivated"
+	if !active {
+		message = "Staff deactivated"
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    staff,
+	})
+}
+
+func (h *StaffHandler) DeleteStaff(c *gin.Context) {
+	id, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.staffService.DeleteStaff(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id"))); err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Staff deleted",
+	})
+}
+
+func staffIDParam(c *gin.Context) (uint, bool) {
+	return uintParam(c, "id", "Invalid staff ID")
+}
+
+func respondStaffError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrStaffNotFound):
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Staff not found",
+		})
+	case errors.Is(err, services.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, services.ErrCannotModifySelf),
+		errors.Is(err, services.ErrNotHomeHospital):
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, services.ErrNoPendingMembership):
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, services.ErrMembershipExists):
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, services.ErrExternalAccount):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, services.ErrHomeHospital):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error() + "; deactivate or delete the account instead",
+		})
+	default:
+		log.Printf("Staff management error: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update staff",
+		})
+	}
+}
+
+// AddMembership invites an existing staff member to the admin's hospital.
+func (h *StaffHandler) AddMembership(c *gin.Context) {
+	var req models.AddMembershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	membership, err := h.membershipService.AddMembership(c.GetString("hospital_id"), uint(c.GetInt("staff_id")), &req)
+	if err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Membership pending acceptance by the staff member",
+		Data:    membership,
+	})
+}
+
+// ListMemberships lists every hospital a staff member of the admin's
+// hospital belongs to.
+func (h *StaffHandler) ListMemberships(c *gin.Context) {
+	id, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.staffService.GetStaffInHospital(c.GetString("hospital_id"), id); err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	memberships, err := h.membershipService.ListMemberships(id)
+	if err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Memberships retrieved",
+		Data:    memberships,
+	})
+}
+
+// RemoveMembership removes a staff member from the admin's hospital.
+func (h *StaffHandler) RemoveMembership(c *gin.Context) {
+	id, ok := staffIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.membershipService.RemoveMembership(c.GetString("hospital_id"), id, uint(c.GetInt("staff_id"))); err != nil {
+		respondStaffError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Staff removed from hospital",
+	})
+}
+
+// MySessions lists the caller's active sessions across all hospitals;
+// ?all=true also includes ended ones.
+func (h *StaffHandler) MySessions(c *gin.Context) {
+	claims := c.MustGet("claims").(*models.JWTClaims)
+	h.listSessions(c, uint(claims.StaffID), "", claims.SessionID)
+}
+
+// RevokeMySession ends one of the caller's sessions, e.g. on a lost device.
+func (h *StaffHandler) RevokeMySession(c *gin.Context) {
+	h.revokeSession(c, uint(c.GetInt("staff_id")), "")
+}
+
+// ListStaffSessions lists the sessions a staff member holds in the admin's
+// hospital.
+func (h *StaffHandler) ListStaffSessions(c *gin.Context) {
+	staff, ok := h.sessionStaff(c)
+	if !ok {
+		return
+	}
+	h.listSessions(c, staff.ID, staff.HospitalID, "")
+}
+
+func (h *StaffHandler) RevokeStaffSession(c *gin.Context) {
+	staff, ok := h.sessionStaff(c)
+	if !ok {
+		return
+	}
+	h.revokeSession(c, staff.ID, staff.HospitalID)
+}
+
+// RevokeStaffSessions ends every session a staff member holds in the
+// admin's hospital.
+func (h *StaffHandler) RevokeStaffSessions(c *gin.Context) {
+	staff, ok := h.sessionStaff(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.sessionService.RevokeAllSessions(staff.ID, staff.HospitalID)
+	if err != nil {
+		log.Printf("Revoke sessions error for staff %d: %v", staff.ID, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to revoke sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Sessions revoked",
+		Data:    gin.H{"revoked": count},
+	})
+}
+
+// sessionStaff resolves the :id parameter to a staff member of the admin's
+// hospital.
+func (h *StaffHandler) sessionStaff(c *gin.Context) (*models.UserStaff, bool) {
+	id, ok := staffIDParam(c)
+	if !ok {
+		return nil, false
+	}
+
+	staff, err := h.staffService.GetStaffInHospital(c.GetString("hospital_id"), id)
+	if err != nil {
+		respondStaffError(c, err)
+		return nil, false
+	}
+	return staff, true
+}
+
+func (h *StaffHandler) listSessions(c *gin.Context, staffID uint, hospitalID, currentID string) {
+	all, _ := strconv.ParseBool(c.Query("all"))
+	sessions, err := h.sessionService.ListSessions(staffID, hospitalID, all)
+	if err != nil {
+		log.Printf("List sessions error for staff %d: %v", staffID, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list sessions",
+		})
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = currentID != "" && sessions[i].ID == currentID
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Sessions retrieved",
+		Data:    sessions,
+	})
+}
+
+func (h *StaffHandler) revokeSession(c *gin.Context, staffID uint, hospitalID string) {
+	if err := h.sessionService.RevokeSession(staffID, hospitalID, c.Param("sid")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "Session not found",
+			})
+			return
+		}
+		log.Printf("Revoke session error for staff %d: %v", staffID, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to revoke session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Session revoked",
+	})
+}
+
+// clientInfo describes the device of the request for session tracking.
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
@@ -1,15 +1,23 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
 	"hospital-api/internal/services"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware requires a valid access token. Tokens issued for a special
+// purpose (see models.TokenPurposeMFAEnroll) are only let through when the
+// purpose is listed in allowedPurposes.
+func AuthMiddleware(issuer *services.TokenIssuer, db *gorm.DB, allowedPurposes ...string) gin.HandlerFunc {
+	tokenService := services.NewTokenService(db, issuer)
 	return func(c *gin.Context) {
-		const prefix = "Bearer "
 		tokenStr := c.GetHeader("Authorization")
 
 		if tokenStr == "" {
@@ -18,24 +26,112 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if len(tokenStr) <= len(prefix) || tokenStr[:len(prefix)] != prefix {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
+		claims, errMsg := parseBearerToken(tokenService, tokenStr, allowedPurposes...)
+		if claims == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errMsg})
 			c.Abort()
 			return
 		}
 
-		tokenStr = tokenStr[len(prefix):]
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// APIKeyHeader carries a service account API key.
+const APIKeyHeader = "X-API-Key"
+
+// AuthOrAPIKeyMiddleware accepts either a staff Bearer token or a service
+// account API key. Service accounts get hospital_id, service_account_id and
+// scopes but no staff_id or role, so the routes behind it must authorize by
+// scope as well as by role.
+func AuthOrAPIKeyMiddleware(issuer *services.TokenIssuer, db *gorm.DB) gin.HandlerFunc {
+	jwtAuth := AuthMiddleware(issuer, db)
+	serviceAccounts := services.NewServiceAccountService(db)
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			jwtAuth(c)
+			return
+		}
 
-		claims, err := services.ValidateJWT(tokenStr)
+		if c.GetHeader("Authorization") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Send either an Authorization header or an API key, not both"})
+			c.Abort()
+			return
+		}
+
+		apiKey, account, err := serviceAccounts.AuthenticateAPIKey(key)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			if !errors.Is(err, services.ErrInvalidAPIKey) {
+				log.Printf("API key validation failed: %v", err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			c.Abort()
+			return
+		}
+
+		c.Set("hospital_id", account.HospitalID)
+		c.Set("service_account_id", account.ID)
+		c.Set("scopes", apiKey.ScopeList())
+		c.Set("actor", fmt.Sprintf("service:%d/%s", account.ID, apiKey.Prefix))
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware authenticates the request when an Authorization
+// header is present and lets anonymous requests through untouched. A header
+// that is present but invalid is still rejected.
+func OptionalAuthMiddleware(issuer *services.TokenIssuer, db *gorm.DB) gin.HandlerFunc {
+	tokenService := services.NewTokenService(db, issuer)
+	return func(c *gin.Context) {
+		tokenStr := c.GetHeader("Authorization")
+		if tokenStr == "" {
+			c.Next()
+			return
+		}
+
+		claims, errMsg := parseBearerToken(tokenService, tokenStr)
+		if claims == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errMsg})
 			c.Abort()
 			return
 		}
 
-		c.Set("staff_id", claims.StaffID)
-		c.Set("hospital_id", claims.HospitalID)
-		c.Set("claims", claims)
+		setClaims(c, claims)
 		c.Next()
 	}
 }
+
+func parseBearerToken(tokenService *services.TokenService, tokenStr string, allowedPurposes ...string) (*models.JWTClaims, string) {
+	const prefix = "Bearer "
+
+	if len(tokenStr) <= len(prefix) || tokenStr[:len(prefix)] != prefix {
+		return nil, "Invalid token format"
+	}
+
+	claims, err := tokenService.ValidateAccessToken(tokenStr[len(prefix):], allowedPurposes...)
+	if err != nil {
+		if errors.Is(err, services.ErrTokenRevoked) {
+			return nil, "Token has been revoked"
+		}
+		if errors.Is(err, services.ErrStaffInactive) {
+			return nil, "Account is deactivated"
+		}
+		if errors.Is(err, services.ErrInvalidToken) {
+			return nil, "Invalid token"
+		}
+		log.Printf("Token validation failed: %v", err)
+		return nil, "Unable to verify token"
+	}
+
+	return claims, ""
+}
+
+func setClaims(c *gin.Context, claims *models.JWTClaims) {
+	c.Set("staff_id", claims.StaffID)
+	c.Set("hospital_id", claims.HospitalID)
+	c.Set("role", claims.Role)
+	c.Set("claims", claims)
+	c.Set("actor", fmt.Sprintf("staff:%d", claims.StaffID))
+}
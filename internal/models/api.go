@@ -1,20 +1,92 @@
 package models
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// CreateStaffRequest is accepted either from a hospital admin, in which case
+// Role applies and the staff joins the admin's hospital, or with an
+// InvitationCode, in which case hospital and role come from the invitation.
 type CreateStaffRequest struct {
-	Username     string `json:"username" binding:"required"`
-	Password     string `json:"password" binding:"required"`
-	HospitalName string `json:"hospital_name" binding:"required"`
+	Username       string `json:"username" binding:"required"`
+	Password       string `json:"password" binding:"required"`
+	Role           Role   `json:"role,omitempty" binding:"omitempty,oneof=admin doctor nurse clerk auditor"`
+	InvitationCode string `json:"invitation_code,omitempty"`
 }
 
+// LoginRequest.HospitalID picks the hospital the token is scoped to and
+// defaults to the staff member's home hospital.
 type LoginRequest struct {
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	HospitalID string `json:"hospital_id,omitempty"`
+}
+
+type SwitchHospitalRequest struct {
+	HospitalID   string `json:"hospital_id" binding:"required"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+type AddMembershipRequest struct {
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Role     Role   `json:"role" binding:"required,oneof=admin doctor nurse clerk auditor"`
 }
 
+// LoginResponse carries either a full token pair or, when a second factor
+// is needed, a short-lived MFAToken to continue the login with.
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token                  string `json:"token,omitempty"`
+	RefreshToken           string `json:"refresh_token,omitempty"`
+	TokenType              string `json:"token_type,omitempty"`
+	ExpiresIn              int64  `json:"expires_in,omitempty"`
+	MFARequired            bool   `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired  bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken               string `json:"mfa_token,omitempty"`
+	PasswordChangeRequired bool   `json:"password_change_required,omitempty"`
+	PasswordChangeToken    string `json:"password_change_token,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFAConfirmResponse struct {
+	RecoveryCodes []string       `json:"recovery_codes"`
+	Tokens        *LoginResponse `json:"tokens,omitempty"`
+}
+
+type HospitalMFAPolicyRequest struct {
+	RequireMFA *bool `json:"require_mfa" binding:"required"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 type CreateStaffResponse struct {
@@ -22,16 +94,250 @@ type CreateStaffResponse struct {
 	StaffID uint   `json:"staff_id"`
 }
 
-type PatientSearchRequest struct {
-	NationalID  string `json:"national_id,omitempty"`
-	PatientHN   string `json:"patient_hn,omitempty"`
-	FirstNameTH string `json:"first_name_th,omitempty"`
-	LastNameTH  string `json:"last_name_th,omitempty"`
-	FirstNameEN string `json:"first_name_en,omitempty"`
-	LastNameEN  string `json:"last_name_en,omitempty"`
-	PassportID  string `json:"passport_id,omitempty"`
-	PhoneNumber string `json:"phone_number,omitempty"`
-	Email       string `json:"email,omitempty"`
+type CreateInvitationRequest struct {
+	Role           Role `json:"role" binding:"required,oneof=admin doctor nurse clerk auditor"`
+	ExpiresInHours int  `json:"expires_in_hours,omitempty" binding:"omitempty,min=1,max=720"`
+}
+
+type CreateInvitationResponse struct {
+	ID        uint      `json:"id"`
+	Code      string    `json:"code"`
+	Role      Role      `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name        string `json:"name" binding:"required,min=3,max=64"`
+	Description string `json:"description,omitempty" binding:"max=255"`
+}
+
+type CreateAPIKeyRequest struct {
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=patient:read"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty" binding:"omitempty,min=1,max=730"`
+}
+
+// RotateAPIKeyRequest.GraceMinutes keeps the old key working for a while so
+// that clients can be switched over without downtime.
+type RotateAPIKeyRequest struct {
+	GraceMinutes int `json:"grace_minutes,omitempty" binding:"omitempty,min=0,max=10080"`
+}
+
+// CreateAPIKeyResponse is the only place the plaintext key is ever shown.
+type CreateAPIKeyResponse struct {
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"api_key"`
+}
+
+type StaffListQuery struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Username string `form:"username"`
+	Role     Role   `form:"role" binding:"omitempty,oneof=admin doctor nurse clerk auditor"`
+	Active   *bool  `form:"active"`
+}
+
+type StaffListResponse struct {
+	Staff    []UserStaff `json:"staff"`
+	Count    int         `json:"count"`
+	Total    int64       `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+}
+
+type UpdateStaffRequest struct {
+	Username *string `json:"username,omitempty" binding:"omitempty,min=3,max=64"`
+	Role     *Role   `json:"role,omitempty" binding:"omitempty,oneof=admin doctor nurse clerk auditor"`
+}
+
+// MatchMode selects how a patient search field is compared. Every mode but
+// exact ignores case.
+type MatchMode string
+
+const (
+	MatchExact    MatchMode = "exact"
+	MatchIExact   MatchMode = "iexact"
+	MatchPrefix   MatchMode = "prefix"
+	MatchContains MatchMode = "contains"
+	// MatchFuzzy combines trigram similarity with phonetic keys and ranks
+	// the results by relevance.
+	MatchFuzzy MatchMode = "fuzzy"
+)
+
+// PatientView is a patient as returned to a caller: personal data masked or
+// omitted for the caller's role and cut down to the requested fields.
+type PatientView map[string]interface{}
+
+// UnmaskPatientRequest reveals the masked personal data of one patient.
+// Fields defaults to every masked field.
+type UnmaskPatientRequest struct {
+	Reason string   `json:"reason" binding:"required,min=10,max=500"`
+	Fields []string `json:"fields,omitempty" binding:"omitempty,dive,oneof=national_id passport_id phone_number email"`
+}
+
+// PatientMatch is a search result. Score is the relevance between 0 and 1
+// of fuzzy searches and omitted otherwise.
+type PatientMatch struct {
+	UserPatient
+	Score float64 `json:"score,omitempty"`
+}
+
+// PatientSearchCriteria is bound from the query string of
+// GET /patient/search or the JSON body of POST /patient/search.
+type PatientSearchCriteria struct {
+	// Q is a single search box value: an identifier, phone number or
+	// email, or otherwise Thai or English names such as "Somchai Jaidee".
+	Q string `json:"q,omitempty" form:"q" binding:"max=200"`
+
+	NationalID   string `json:"national_id,omitempty" form:"national_id" binding:"omitempty,national_id"`
+	PatientHN    string `json:"patient_hn,omitempty" form:"patient_hn" binding:"omitempty,hn"`
+	FirstNameTH  string `json:"first_name_th,omitempty" form:"first_name_th" binding:"max=100"`
+	MiddleNameTH string `json:"middle_name_th,omitempty" form:"middle_name_th" binding:"max=100"`
+	LastNameTH   string `json:"last_name_th,omitempty" form:"last_name_th" binding:"max=100"`
+	FirstNameEN  string `json:"first_name_en,omitempty" form:"first_name_en" binding:"max=100"`
+	MiddleNameEN string `json:"middle_name_en,omitempty" form:"middle_name_en" binding:"max=100"`
+	LastNameEN   string `json:"last_name_en,omitempty" form:"last_name_en" binding:"max=100"`
+	PassportID   string `json:"passport_id,omitempty" form:"passport_id" binding:"omitempty,passport"`
+	PhoneNumber  string `json:"phone_number,omitempty" form:"phone_number" binding:"omitempty,thai_mobile"`
+	Email        string `json:"email,omitempty" form:"email" binding:"max=255"`
+
+	// Demographic filters. Dates use YYYY-MM-DD and ranges include both
+	// ends; ages are whole years as of today.
+	DateOfBirth string   `json:"date_of_birth,omitempty" form:"date_of_birth" binding:"omitempty,datetime=2006-01-02"`
+	DOBFrom     string   `json:"dob_from,omitempty" form:"dob_from" binding:"omitempty,datetime=2006-01-02"`
+	DOBTo       string   `json:"dob_to,omitempty" form:"dob_to" binding:"omitempty,datetime=2006-01-02"`
+	AgeMin      *int     `json:"age_min,omitempty" form:"age_min" binding:"omitempty,min=0,max=150"`
+	AgeMax      *int     `json:"age_max,omitempty" form:"age_max" binding:"omitempty,min=0,max=150"`
+	Gender      []Gender `json:"gender,omitempty" form:"gender" binding:"omitempty,dive,oneof=M F"`
+	CreatedFrom string   `json:"created_from,omitempty" form:"created_from" binding:"omitempty,datetime=2006-01-02"`
+	CreatedTo   string   `json:"created_to,omitempty" form:"created_to" binding:"omitempty,datetime=2006-01-02"`
+
+	// Match overrides the default match mode per field, e.g.
+	// {"first_name_en": "prefix"}. Query strings use
+	// match[first_name_en]=prefix.
+	Match map[string]MatchMode `json:"match,omitempty" form:"-"`
+
+	PatientPageRequest
+}
+
+// PatientPageRequest selects one page of patient search results, either by
+// offset or by the opaque cursor of the previous page.
+type PatientPageRequest struct {
+	Limit        int    `json:"limit,omitempty" form:"limit" binding:"min=0"`
+	Offset       int    `json:"offset,omitempty" form:"offset" binding:"min=0"`
+	Cursor       string `json:"cursor,omitempty" form:"cursor" binding:"max=1024"`
+	Sort         string `json:"sort,omitempty" form:"sort"`
+	Order        string `json:"order,omitempty" form:"order" binding:"omitempty,oneof=asc desc"`
+	IncludeTotal bool   `json:"include_total,omitempty" form:"include_total"`
+}
+
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Sort       string `json:"sort"`
+	Order      string `json:"order"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total is only counted when include_total is set.
+	Total *int64 `json:"total,omitempty"`
+}
+
+type PatientSearchResponse struct {
+	Patients   []PatientView `json:"patients"`
+	Count      int           `json:"count"`
+	Pagination Pagination    `json:"pagination"`
+	// QueryType tells what the q parameter was searched as.
+	QueryType string `json:"query_type,omitempty"`
+}
+
+// PatientSuggestRequest is the typeahead input of GET /patient/suggest.
+type PatientSuggestRequest struct {
+	Q     string `form:"q" binding:"required,min=2,max=100"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=20"`
+}
+
+// PatientSuggestion is the minimal patient summary shown while typing. It
+// follows the caller's personal data policy: the national ID is at most
+// masked, and fields the caller may not see are left out.
+type PatientSuggestion struct {
+	ID            uint   `json:"id"`
+	PatientHN     string `json:"patient_hn"`
+	NationalID    string `json:"national_id,omitempty"`
+	DisplayNameTH string `json:"display_name_th,omitempty"`
+	DisplayNameEN string `json:"display_name_en,omitempty"`
+	DateOfBirth   string `json:"date_of_birth,omitempty"`
+}
+
+// PatientBatchRequest looks up many patients at once. Type restricts the
+// identifiers to one kind; by default each may be a national ID, passport
+// number or HN.
+type PatientBatchRequest struct {
+	Identifiers []string `json:"identifiers" binding:"required,min=1,dive,required,max=32"`
+	Type        string   `json:"type,omitempty" binding:"omitempty,oneof=national_id passport_id patient_hn"`
+}
+
+type PatientBatchResult struct {
+	Found bool `json:"found"`
+	// MatchedBy names the identifier field that matched.
+	MatchedBy string      `json:"matched_by,omitempty"`
+	Patient   PatientView `json:"patient,omitempty"`
+}
+
+// PatientBatchResponse maps every requested identifier to its result.
+type PatientBatchResponse struct {
+	Results  map[string]PatientBatchResult `json:"results"`
+	Found    int                           `json:"found"`
+	NotFound int                           `json:"not_found"`
+}
+
+// PatientRequest holds the patient fields a client may set. Dates use the
+// YYYY-MM-DD format.
+type PatientRequest struct {
+	PatientHN    string `json:"patient_hn" binding:"required,hn"`
+	FirstNameTH  string `json:"first_name_th" binding:"required,max=100"`
+	MiddleNameTH string `json:"middle_name_th,omitempty" binding:"max=100"`
+	LastNameTH   string `json:"last_name_th" binding:"required,max=100"`
+	FirstNameEN  string `json:"first_name_en,omitempty" binding:"max=100"`
+	MiddleNameEN string `json:"middle_name_en,omitempty" binding:"max=100"`
+	LastNameEN   string `json:"last_name_en,omitempty" binding:"max=100"`
+	DateOfBirth  string `json:"date_of_birth" binding:"required,datetime=2006-01-02"`
+	PassportID   string `json:"passport_id,omitempty" binding:"omitempty,passport"`
+	PhoneNumber  string `json:"phone_number,omitempty" binding:"omitempty,thai_mobile"`
+	Email        string `json:"email,omitempty" binding:"omitempty,email,max=255"`
+	Gender       Gender `json:"gender" binding:"required,oneof=M F"`
+}
+
+type CreatePatientRequest struct {
+	NationalID string `json:"national_id" binding:"required,national_id"`
+	PatientRequest
+}
+
+// PatchPatientRequest changes only the fields that are present. An empty
+// string clears an optional field.
+type PatchPatientRequest struct {
+	PatientHN    *string `json:"patient_hn,omitempty" binding:"omitempty,hn"`
+	FirstNameTH  *string `json:"first_name_th,omitempty" binding:"omitempty,min=1,max=100"`
+	MiddleNameTH *string `json:"middle_name_th,omitempty" binding:"omitempty,max=100"`
+	LastNameTH   *string `json:"last_name_th,omitempty" binding:"omitempty,min=1,max=100"`
+	FirstNameEN  *string `json:"first_name_en,omitempty" binding:"omitempty,max=100"`
+	MiddleNameEN *string `json:"middle_name_en,omitempty" binding:"omitempty,max=100"`
+	LastNameEN   *string `json:"last_name_en,omitempty" binding:"omitempty,max=100"`
+	DateOfBirth  *string `json:"date_of_birth,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	PassportID   *string `json:"passport_id,omitempty" binding:"omitempty,passport|len=0"`
+	PhoneNumber  *string `json:"phone_number,omitempty" binding:"omitempty,thai_mobile|len=0"`
+	Email        *string `json:"email,omitempty" binding:"omitempty,max=255,email|len=0"`
+	Gender       *Gender `json:"gender,omitempty" binding:"omitempty,oneof=M F"`
+}
+
+// HospitalHNFormatRequest sets the regular expression HNs of the hospital
+// must match. An empty pattern only applies the general HN format.
+type HospitalHNFormatRequest struct {
+	Pattern string `json:"pattern" binding:"max=200"`
+}
+
+// FieldError describes why one request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 type APIResponse struct {
@@ -41,8 +347,36 @@ type APIResponse struct {
 	Error   string      `json:"error,omitempty"`
 }
 
+// Token purposes restrict what a non-access token may be used for. Access
+// tokens carry no purpose.
+const (
+	TokenPurposeMFA            = "mfa"
+	TokenPurposeMFAEnroll      = "mfa_enroll"
+	TokenPurposePasswordChange = "password_change"
+)
+
 type JWTClaims struct {
 	StaffID    int    `json:"staff_id"`
 	HospitalID string `json:"hospital_id"`
+	Role       Role   `json:"role"`
+	Purpose    string `json:"purpose,omitempty"`
+	SessionID  string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// JWK is a public signing key in RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
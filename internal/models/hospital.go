@@ -3,10 +3,12 @@ package models
 import "time"
 
 type Hospital struct {
-	ID        string        `json:"id" gorm:"primaryKey" `
-	Name      string        `json:"name" gorm:"unique" `
-	Staff     []UserStaff   `gorm:"foreignKey:HospitalID" json:"-"`
-	Patients  []UserPatient `gorm:"foreignKey:HospitalID" json:"-"`
-	CreatedAt time.Time     `json:"-"`
-	UpdatedAt time.Time     `json:"-"`
+	ID         string        `json:"id" gorm:"primaryKey" `
+	Name       string        `json:"name" gorm:"unique" `
+	RequireMFA bool          `json:"require_mfa" gorm:"not null;default:false"`
+	HNPattern  string        `json:"hn_pattern,omitempty"`
+	Staff      []UserStaff   `gorm:"foreignKey:HospitalID" json:"-"`
+	Patients   []UserPatient `gorm:"foreignKey:HospitalID" json:"-"`
+	CreatedAt  time.Time     `json:"-"`
+	UpdatedAt  time.Time     `json:"-"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// StaffInvitation is a single-use code that lets a new staff account join
+// a hospital with a role chosen by one of that hospital's admins. Only the
+// SHA-256 hash of the code is stored.
+type StaffInvitation struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CodeHash    string     `json:"-" gorm:"uniqueIndex"`
+	HospitalID  string     `json:"hospital_id" gorm:"index"`
+	Role        Role       `json:"role" gorm:"type:varchar(20)"`
+	CreatedByID uint       `json:"created_by_id"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+	UsedByID    *uint      `json:"used_by_id,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (i *StaffInvitation) IsUsable(now time.Time) bool {
+	return i.UsedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+type MembershipStatus string
+
+const (
+	MembershipActive  MembershipStatus = "active"
+	MembershipPending MembershipStatus = "pending"
+)
+
+// MembershipSource records who granted a membership. Memberships granted
+// by the identity provider follow its claims and are revoked when a login
+// no longer carries them; those granted by admins are left alone.
+type MembershipSource string
+
+const (
+	MembershipSourceAdmin MembershipSource = "admin"
+	MembershipSourceIdP   MembershipSource = "idp"
+)
+
+// StaffHospitalMembership grants a staff member access to a hospital with a
+// role that applies only there. Tokens are scoped to one membership at a
+// time; UserStaff.HospitalID and UserStaff.Role mirror the home membership,
+// i.e. the hospital that created the account.
+//
+// A membership added by another hospital's admin stays pending until the
+// staff member accepts it and cannot be used to scope a token before then.
+type StaffHospitalMembership struct {
+	ID         uint             `json:"id" gorm:"primaryKey"`
+	StaffID    uint             `json:"staff_id" gorm:"not null;uniqueIndex:idx_membership_staff_hospital"`
+	HospitalID string           `json:"hospital_id" gorm:"not null;uniqueIndex:idx_membership_staff_hospital;index"`
+	Hospital   *Hospital        `json:"hospital,omitempty" gorm:"foreignKey:HospitalID"`
+	Role       Role             `json:"role" gorm:"type:varchar(20);not null"`
+	Status     MembershipStatus `json:"status" gorm:"type:varchar(20);not null;default:'active'"`
+	Source     MembershipSource `json:"source" gorm:"type:varchar(10);not null;default:'admin'"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// OIDCLoginState remembers an authorization request between the redirect to
+// the identity provider and the callback. It is single-use and only the
+// SHA-256 hash of the state parameter is stored.
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"uniqueIndex"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	HospitalID   string    // hospital requested for the login, if any
+	ExpiresAt    time.Time `gorm:"index"`
+	CreatedAt    time.Time
+}
+
+const (
+	AuthProviderLocal = "local"
+	AuthProviderOIDC  = "oidc"
+)
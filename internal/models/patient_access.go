@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PatientAccessLog records every time a staff member revealed a patient's
+// masked personal data, and why.
+type PatientAccessLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	HospitalID string    `json:"hospital_id" gorm:"index"`
+	PatientID  uint      `json:"patient_id" gorm:"index"`
+	StaffID    uint      `json:"staff_id" gorm:"index"`
+	Role       Role      `json:"role"`
+	Fields     string    `json:"fields"` // comma-separated
+	Reason     string    `json:"reason"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
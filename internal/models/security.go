@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// LoginAttempt is the security log of staff login attempts. It is also the
+// source for per-IP failure counting.
+type LoginAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Username  string    `json:"username" gorm:"index"`
+	StaffID   *uint     `json:"staff_id,omitempty" gorm:"index"`
+	IPAddress string    `json:"ip_address" gorm:"index:idx_login_attempts_ip_created"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_login_attempts_ip_created"`
+}
+
+// LoginLockout counts failed logins for a username that has no staff
+// account, with the same limits as the account counters on UserStaff, so
+// that responses do not reveal which usernames exist.
+type LoginLockout struct {
+	Username            string     `json:"username" gorm:"primaryKey"`
+	FailedLoginAttempts int        `json:"failed_login_attempts" gorm:"not null;default:0"`
+	LastFailedLoginAt   *time.Time `json:"last_failed_login_at,omitempty"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
+}
+
+func (l *LoginLockout) IsLocked(now time.Time) bool {
+	return l.LockedUntil != nil && now.Before(*l.LockedUntil)
+}
+
+const (
+	LoginReasonSuccess         = "success"
+	LoginReasonUnknownUser     = "unknown_user"
+	LoginReasonBadPassword     = "bad_password"
+	LoginReasonBadMFACode      = "bad_mfa_code"
+	LoginReasonAccountLocked   = "account_locked"
+	LoginReasonAccountInactive = "account_inactive"
+	LoginReasonIPBlocked       = "ip_blocked"
+	LoginReasonUnlockedByAdmin = "unlocked_by_admin"
+)
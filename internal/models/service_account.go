@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// API key scopes. A key only reaches the routes that accept one of its
+// scopes.
+const (
+	ScopePatientRead = "patient:read"
+)
+
+var APIKeyScopes = []string{ScopePatientRead}
+
+// ServiceAccount is a non-human caller, such as a lab system or a kiosk,
+// that belongs to one hospital and authenticates with API keys.
+type ServiceAccount struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"not null;uniqueIndex:idx_service_account_hospital_name"`
+	Description string    `json:"description,omitempty"`
+	HospitalID  string    `json:"hospital_id" gorm:"not null;uniqueIndex:idx_service_account_hospital_name"`
+	Hospital    Hospital  `json:"-" gorm:"foreignKey:HospitalID"`
+	IsActive    bool      `json:"is_active" gorm:"not null;default:true"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// APIKey is a credential of a service account. Keys look like
+// "hak_<prefix>_<secret>"; the prefix is stored in clear to find the key
+// and to identify it in logs, the full key only as a SHA-256 hash.
+type APIKey struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	ServiceAccountID uint       `json:"service_account_id" gorm:"not null;index"`
+	Prefix           string     `json:"prefix" gorm:"not null;uniqueIndex"`
+	KeyHash          string     `json:"-" gorm:"not null"`
+	Scopes           string     `json:"scopes"` // comma-separated
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedByID      uint       `json:"created_by_id"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k *APIKey) IsUsable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// StaffSession is one login of a staff member on one device. It lives as
+// long as its refresh token family; access tokens carry its ID in the "sid"
+// claim so that revoking the session cuts them off immediately.
+type StaffSession struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:32"`
+	StaffID    uint       `json:"staff_id" gorm:"not null;index"`
+	HospitalID string     `json:"hospital_id" gorm:"index"`
+	FamilyID   string     `json:"-" gorm:"uniqueIndex"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Current marks the caller's own session in listings.
+	Current bool `json:"current" gorm:"-"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// RefreshToken is a rotating, single-use refresh token. Tokens that descend
+// from the same login share a FamilyID so that reuse of an already rotated
+// token can revoke the whole chain. Only the SHA-256 hash is stored.
+type RefreshToken struct {
+	ID           uint   `gorm:"primaryKey"`
+	TokenHash    string `gorm:"uniqueIndex"`
+	FamilyID     string `gorm:"index"`
+	StaffID      uint   `gorm:"index"`
+	HospitalID   string // hospital the issued access tokens are scoped to
+	ExpiresAt    time.Time
+	RevokedAt    *time.Time
+	ReplacedByID *uint
+	CreatedAt    time.Time
+}
+
+// RevokedToken blocks an access token by its jti until the token would have
+// expired anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey"`
+	StaffID   uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+	RevokedAt time.Time
+}
+
+// PasswordResetToken is a one-time token issued by a hospital admin and
+// delivered to the staff member out of band.
+type PasswordResetToken struct {
+	ID          uint   `gorm:"primaryKey"`
+	TokenHash   string `gorm:"uniqueIndex"`
+	StaffID     uint   `gorm:"index"`
+	CreatedByID uint
+	ExpiresAt   time.Time
+	UsedAt      *time.Time
+	CreatedAt   time.Time
+}
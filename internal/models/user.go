@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"hospital-api/internal/phonetic"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Gender string
 
@@ -9,32 +14,127 @@ const (
 	Female Gender = "F"
 )
 
+type Role string
+
+const (
+	RoleAdmin   Role = "admin"
+	RoleDoctor  Role = "doctor"
+	RoleNurse   Role = "nurse"
+	RoleClerk   Role = "clerk"
+	RoleAuditor Role = "auditor"
+)
+
+// Roles lists every role a staff account can hold.
+var Roles = []Role{RoleAdmin, RoleDoctor, RoleNurse, RoleClerk, RoleAuditor}
+
+func (r Role) IsValid() bool {
+	for _, role := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// UserPatient is a patient record of one hospital. Every hospital keeps its
+// own record, so the national ID, HN and passport ID are unique only within
+// a hospital. ID is the reference API callers use for the record, since
+// they only ever see the national ID masked.
 type UserPatient struct {
-	NationalID   string    `json:"national_id" gorm:"primaryKey" `
-	PatientHN    string    `json:"patient_hn" gorm:"unique" `
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	NationalID   string    `json:"national_id" gorm:"not null;uniqueIndex:idx_patient_hospital_national_id,priority:2"`
+	PatientHN    string    `json:"patient_hn" gorm:"uniqueIndex:idx_patient_hospital_hn,priority:2" `
 	FirstNameTH  string    `json:"first_name_th"`
 	MiddleNameTH string    `json:"middle_name_th,omitempty"`
 	LastNameTH   string    `json:"last_name_th"`
 	FirstNameEN  string    `json:"first_name_en"`
 	MiddleNameEN string    `json:"middle_name_en,omitempty"`
 	LastNameEN   string    `json:"last_name_en"`
-	DateOfBirth  time.Time `json:"date_of_birth"`
-	PassportID   string    `json:"passport_id,omitempty" gorm:"unique"`
-	PhoneNumber  string    `json:"phone_number,omitempty"`
+	DateOfBirth  time.Time `json:"date_of_birth" gorm:"index"`
+	PassportID   *string   `json:"passport_id,omitempty" gorm:"uniqueIndex:idx_patient_hospital_passport,priority:2"`
+	PhoneNumber  string    `json:"phone_number,omitempty" gorm:"index"`
 	Email        string    `json:"email,omitempty"`
 	Gender       Gender    `json:"gender" gorm:"type:varchar(1)"`
-	HospitalID   string    `json:"hospital_id"`
+	HospitalID   string    `json:"hospital_id" gorm:"not null;uniqueIndex:idx_patient_hospital_national_id,priority:1;uniqueIndex:idx_patient_hospital_hn,priority:1;uniqueIndex:idx_patient_hospital_passport,priority:1"`
 	Hospital     Hospital  `json:"-" gorm:"foreignKey:HospitalID"`
-	CreatedAt    time.Time `json:"-"`
+	CreatedAt    time.Time `json:"-" gorm:"index"`
 	UpdatedAt    time.Time `json:"-"`
+
+	// Phonetic keys of the names for fuzzy search, maintained by
+	// BeforeSave: a Thai sound-alike key and the English Soundex code.
+	FirstNameTHKey string `json:"-" gorm:"index;not null;default:''"`
+	LastNameTHKey  string `json:"-" gorm:"index;not null;default:''"`
+	FirstNameENKey string `json:"-" gorm:"index;not null;default:''"`
+	LastNameENKey  string `json:"-" gorm:"index;not null;default:''"`
+}
+
+// SetPhoneticKeys recomputes the phonetic keys from the names.
+func (p *UserPatient) SetPhoneticKeys() {
+	p.FirstNameTHKey = phonetic.ThaiKey(p.FirstNameTH)
+	p.LastNameTHKey = phonetic.ThaiKey(p.LastNameTH)
+	p.FirstNameENKey = phonetic.Soundex(p.FirstNameEN)
+	p.LastNameENKey = phonetic.Soundex(p.LastNameEN)
+}
+
+func (p *UserPatient) BeforeSave(tx *gorm.DB) error {
+	p.SetPhoneticKeys()
+	return nil
 }
 
 type UserStaff struct {
-	ID         uint      `json:"id" gorm:"primaryKey"`
-	Username   string    `json:"username" gorm:"unique"`
-	Password   string    `json:"-"`
-	HospitalID string    `json:"hospital_id"`
-	Hospital   Hospital  `json:"-" gorm:"foreignKey:HospitalID"`
-	CreatedAt  time.Time `json:"-"`
-	UpdatedAt  time.Time `json:"-"`
+	ID         uint     `json:"id" gorm:"primaryKey"`
+	Username   string   `json:"username" gorm:"unique"`
+	Password   string   `json:"-"`
+	Role       Role     `json:"role" gorm:"type:varchar(20);not null;default:'clerk'"`
+	HospitalID string   `json:"hospital_id"`
+	Hospital   Hospital `json:"-" gorm:"foreignKey:HospitalID"`
+
+	// SSO accounts have no password; ExternalID is "<issuer>|<subject>".
+	AuthProvider string  `json:"auth_provider" gorm:"type:varchar(20);not null;default:'local'"`
+	ExternalID   *string `json:"-" gorm:"uniqueIndex"`
+
+	FailedLoginAttempts int        `json:"failed_login_attempts" gorm:"not null;default:0"`
+	LastFailedLoginAt   *time.Time `json:"last_failed_login_at,omitempty"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
+
+	// MFASecret is the confirmed TOTP secret; MFAPendingSecret holds a secret
+	// during enrolment until the first code is confirmed.
+	MFAEnabled       bool   `json:"mfa_enabled" gorm:"not null;default:false"`
+	MFASecret        string `json:"-"`
+	MFAPendingSecret string `json:"-"`
+	MFALastUsedStep  int64  `json:"-"`
+
+	MustChangePassword bool       `json:"must_change_password" gorm:"not null;default:false"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
+
+	// Inactive staff cannot log in and their tokens stop working.
+	IsActive      bool       `json:"is_active" gorm:"not null;default:true"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+func (s *UserStaff) IsLocked(now time.Time) bool {
+	return s.LockedUntil != nil && now.Before(*s.LockedUntil)
+}
+
+// StaffRecoveryCode is a single-use MFA fallback code. Only the SHA-256 hash
+// is stored.
+type StaffRecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	StaffID   uint   `gorm:"index"`
+	CodeHash  string `gorm:"index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// StaffPasswordHistory keeps earlier password hashes so that the password
+// policy can refuse reuse.
+type StaffPasswordHistory struct {
+	ID        uint `gorm:"primaryKey"`
+	StaffID   uint `gorm:"index"`
+	Hash      string
+	CreatedAt time.Time
 }
@@ -0,0 +1,83 @@
+package notifier
+
+import (
+	"fmt"
+	"hospital-api/internal/configs"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is an out-of-band notification to a staff member, such as a
+// password reset token.
+type Message struct {
+	Recipient string
+	Subject   string
+	Body      string
+}
+
+// Notifier delivers messages to staff. Production deployments use
+// WebhookNotifier to reach an e-mail or SMS gateway; LogNotifier and
+// FileNotifier are stand-ins for development.
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// New returns the notifier selected by NOTIFIER ("webhook", or "log" and
+// "file" in development). The stand-ins write reset tokens in plain text,
+// so outside development NOTIFIER must name a real delivery channel.
+func New(cfg configs.Config) (Notifier, error) {
+	switch cfg.Notifier {
+	case "", "log", "file":
+		if !cfg.IsDevelopment() {
+			return nil, fmt.Errorf("NOTIFIER=%q is for development only; set NOTIFIER=webhook in %q mode", cfg.Notifier, cfg.AppEnv)
+		}
+		if cfg.Notifier == "file" {
+			return NewFileNotifier(cfg.NotifierFilePath), nil
+		}
+		return LogNotifier{}, nil
+	case "webhook":
+		if cfg.NotifierWebhookURL == "" {
+			return nil, fmt.Errorf("NOTIFIER_WEBHOOK_URL must be set when NOTIFIER=webhook")
+		}
+		return NewWebhookNotifier(cfg.NotifierWebhookURL, cfg.NotifierWebhookToken), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", cfg.Notifier)
+	}
+}
+
+type LogNotifier struct{}
+
+func (LogNotifier) Send(msg Message) error {
+	log.Printf("NOTIFY to=%s subject=%q\n%s", msg.Recipient, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileNotifier appends messages to a local file.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) Send(msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %v", err)
+	}
+	defer f.Close()
+
+	entry := fmt.Sprintf("--- %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), msg.Recipient, msg.Subject, msg.Body)
+	if _, err := f.WriteString(entry); err != nil {
+		return fmt.Errorf("failed to write notification: %v", err)
+	}
+	return nil
+}
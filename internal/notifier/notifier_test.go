@@ -0,0 +1,73 @@
+package notifier
+
+import (
+	"encoding/json"
+	"hospital-api/internal/configs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		appEnv   string
+		notifier string
+		webhook  string
+		wantErr  bool
+	}{
+		{"log in development", "development", "log", "", false},
+		{"default in development", "development", "", "", false},
+		{"file in development", "development", "file", "", false},
+		{"log in production", "production", "log", "", true},
+		{"file in production", "production", "file", "", true},
+		{"default in production", "production", "", "", true},
+		{"webhook in production", "production", "webhook", "https://messaging.example.org/notify", false},
+		{"webhook without URL", "production", "webhook", "", true},
+		{"unknown notifier", "development", "pigeon", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := configs.Config{AppEnv: tt.appEnv, Notifier: tt.notifier, NotifierWebhookURL: tt.webhook}
+			_, err := New(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got webhookPayload
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	msg := Message{Recipient: "nurse01", Subject: "Password reset", Body: "Reset token: abc"}
+	if err := NewWebhookNotifier(server.URL, "secret").Send(msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got != (webhookPayload{Recipient: msg.Recipient, Subject: msg.Subject, Body: msg.Body}) {
+		t.Errorf("payload = %+v", got)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+}
+
+func TestWebhookNotifierError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	if err := NewWebhookNotifier(server.URL, "").Send(Message{Recipient: "nurse01"}); err == nil {
+		t.Error("Send succeeded although the gateway failed")
+	}
+}
@@ -0,0 +1,53 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier posts messages as JSON to a messaging gateway, which
+// delivers them by e-mail, SMS or chat.
+type WebhookNotifier struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url, token string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, token: token, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type webhookPayload struct {
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+}
+
+func (n *WebhookNotifier) Send(msg Message) error {
+	payload, err := json.Marshal(webhookPayload{Recipient: msg.Recipient, Subject: msg.Subject, Body: msg.Body})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build notification request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification webhook returned %s", resp.Status)
+	}
+	return nil
+}
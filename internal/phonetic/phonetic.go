@@ -0,0 +1,127 @@
+// Package phonetic derives sound-alike keys for patient names so that
+// common spelling mistakes still find the right record.
+package phonetic
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	thanthakhat = '์' // ์ silences the preceding consonant
+	maiTaikhu   = '็' // ็
+	nikhahit    = 'ํ' // ํ
+	maiYamok    = 'ๆ' // ๆ
+	paiyannoi   = 'ฯ' // ฯ
+)
+
+// thaiConsonantClass maps consonants that sound alike to one
+// representative, e.g. ส ศ ษ ซ all become ส and ร ล ฬ become ล.
+var thaiConsonantClass = map[rune]rune{
+	'ศ': 'ส', 'ษ': 'ส', 'ซ': 'ส',
+	'ร': 'ล', 'ฬ': 'ล',
+	'ธ': 'ท', 'ฑ': 'ท', 'ฒ': 'ท', 'ถ': 'ท', 'ฐ': 'ท',
+	'ข': 'ค', 'ฃ': 'ค', 'ฅ': 'ค', 'ฆ': 'ค',
+	'ผ': 'พ', 'ภ': 'พ',
+	'ฝ': 'ฟ',
+	'ฉ': 'ช', 'ฌ': 'ช',
+	'ญ': 'ย',
+	'ณ': 'น',
+	'ฎ': 'ด',
+	'ฏ': 'ต',
+	'ฮ': 'ห',
+	'ั': 'ะ',
+	'ใ': 'ไ',
+}
+
+// ThaiKey returns a phonetic key for Thai text: tone marks and silenced
+// consonants are dropped, sound-alike letters merged and repeated sounds
+// collapsed. Non-Thai characters are ignored.
+func ThaiKey(s string) string {
+	runes := []rune(s)
+	silent := make([]bool, len(runes))
+	for i, r := range runes {
+		if r != thanthakhat || i == 0 {
+			continue
+		}
+		// The silenced consonant may carry a vowel, as in ดิ์ or ตุ์.
+		silent[i-1] = true
+		if (runes[i-1] == 'ิ' || runes[i-1] == 'ุ') && i >= 2 {
+			silent[i-2] = true
+		}
+	}
+
+	var b strings.Builder
+	var last rune
+	for i, r := range runes {
+		if !isThai(r) || silent[i] {
+			continue
+		}
+		if isToneMark(r) || r == thanthakhat || r == maiTaikhu || r == nikhahit || r == maiYamok || r == paiyannoi {
+			continue
+		}
+		if class, ok := thaiConsonantClass[r]; ok {
+			r = class
+		}
+		if r == last {
+			continue
+		}
+		b.WriteRune(r)
+		last = r
+	}
+	return b.String()
+}
+
+// soundexCodes holds the American Soundex digit of each letter; vowels
+// and H, W, Y have none.
+var soundexCodes = map[rune]byte{
+	'B': '1', 'F': '1', 'P': '1', 'V': '1',
+	'C': '2', 'G': '2', 'J': '2', 'K': '2', 'Q': '2', 'S': '2', 'X': '2', 'Z': '2',
+	'D': '3', 'T': '3',
+	'L': '4',
+	'M': '5', 'N': '5',
+	'R': '6',
+}
+
+// Soundex returns the American Soundex code of the Latin letters in s,
+// e.g. "Somchai" becomes "S522". It returns "" when s has no Latin letter.
+func Soundex(s string) string {
+	var letters []rune
+	for _, r := range strings.ToUpper(s) {
+		if r >= 'A' && r <= 'Z' {
+			letters = append(letters, r)
+		}
+	}
+	if len(letters) == 0 {
+		return ""
+	}
+
+	code := []byte{byte(letters[0])}
+	last := soundexCodes[letters[0]]
+	for _, r := range letters[1:] {
+		digit, ok := soundexCodes[r]
+		switch {
+		case ok && digit != last:
+			code = append(code, digit)
+			if len(code) == 4 {
+				return string(code)
+			}
+			last = digit
+		case !ok && r != 'H' && r != 'W':
+			// Vowels separate equal codes; H and W do not.
+			last = 0
+		}
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+func isThai(r rune) bool {
+	return unicode.Is(unicode.Thai, r)
+}
+
+func isToneMark(r rune) bool {
+	return r >= '่' && r <= '๋'
+}
@@ -0,0 +1,62 @@
+package router
+
+import (
+	"hospital-api/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireRoles only lets the request through when the role set by
+// middleware.AuthMiddleware is one of the given roles.
+func requireRoles(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("role")
+		role, ok := value.(models.Role)
+		if !exists || !ok || role == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Forbidden: role not found",
+			})
+			return
+		}
+
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Forbidden: role '" + string(role) + "' is not allowed to access this resource",
+		})
+	}
+}
+
+// allow lets staff with one of roles through, as well as service accounts
+// whose API key carries scope.
+func allow(scope string, roles ...models.Role) gin.HandlerFunc {
+	byRole := requireRoles(roles...)
+	return func(c *gin.Context) {
+		if _, isService := c.Get("service_account_id"); !isService {
+			byRole(c)
+			return
+		}
+
+		scopes, _ := c.Get("scopes")
+		granted, _ := scopes.([]string)
+		for _, s := range granted {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Forbidden: API key lacks the '" + scope + "' scope",
+		})
+	}
+}
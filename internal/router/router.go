@@ -1,33 +1,157 @@
 package router
 
 import (
+	"hospital-api/internal/configs"
 	"hospital-api/internal/handlers"
 	"hospital-api/internal/middleware"
+	"hospital-api/internal/models"
+	"hospital-api/internal/notifier"
+	"hospital-api/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupRouter(db *gorm.DB) *gin.Engine {
+// patientReaders may look up patient records.
+var patientReaders = []models.Role{
+	models.RoleAdmin,
+	models.RoleDoctor,
+	models.RoleNurse,
+	models.RoleClerk,
+	models.RoleAuditor,
+}
+
+// patientWriters may register and update patients. Deleting a record is
+// reserved for admins.
+var patientWriters = []models.Role{
+	models.RoleAdmin,
+	models.RoleDoctor,
+	models.RoleNurse,
+	models.RoleClerk,
+}
+
+// patientUnmaskers may reveal masked patient identifiers; every unmask is
+// recorded in the patient access log.
+var patientUnmaskers = []models.Role{
+	models.RoleAdmin,
+	models.RoleDoctor,
+	models.RoleNurse,
+}
+
+// SetupRouter wires all routes. oidc may be nil, which leaves single
+// sign-on disabled.
+func SetupRouter(db *gorm.DB, issuer *services.TokenIssuer, n notifier.Notifier, hasher services.PasswordHasher, oidc *services.OIDCConfig) *gin.Engine {
 	r := gin.Default()
-	_ = r.SetTrustedProxies(nil)
+	_ = r.SetTrustedProxies(configs.Envs.TrustedProxies)
+	r.Use(middleware.AccessLog())
 
-	staffHandler := handlers.NewStaffHandler(db)
+	staffHandler := handlers.NewStaffHandler(db, issuer, n, hasher)
 	patientHandler := handlers.NewPatientHandler(db)
+	invitationHandler := handlers.NewInvitationHandler(db)
+	jwksHandler := handlers.NewJWKSHandler(issuer)
+	mfaHandler := handlers.NewMFAHandler(db, issuer)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(db)
+
+	auth := middleware.AuthMiddleware(issuer, db)
+
+	r.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
 
 	api := r.Group("/api/v1")
 
 	staffRoutes := api.Group("/staff")
 	{
-		staffRoutes.POST("/create", staffHandler.CreateStaff)
+		staffRoutes.POST("/create", middleware.OptionalAuthMiddleware(issuer, db), staffHandler.CreateStaff)
 		staffRoutes.POST("/login", staffHandler.Login)
+		staffRoutes.POST("/login/mfa", staffHandler.LoginMFA)
+		staffRoutes.POST("/refresh", staffHandler.RefreshToken)
+		staffRoutes.POST("/logout", auth, staffHandler.Logout)
+		staffRoutes.POST("/switch-hospital", auth, staffHandler.SwitchHospital)
+		staffRoutes.GET("/me/hospitals", auth, staffHandler.MyHospitals)
+		staffRoutes.POST("/me/hospitals/:hospital_id/accept", auth, staffHandler.AcceptMembership)
+		staffRoutes.DELETE("/me/hospitals/:hospital_id", auth, staffHandler.DeclineMembership)
+		staffRoutes.GET("/sessions", auth, staffHandler.MySessions)
+		staffRoutes.DELETE("/sessions/:sid", auth, staffHandler.RevokeMySession)
+		staffRoutes.GET("", auth, requireRoles(models.RoleAdmin), staffHandler.ListStaff)
+		staffRoutes.POST("/memberships", auth, requireRoles(models.RoleAdmin), staffHandler.AddMembership)
+		staffRoutes.GET("/:id", auth, requireRoles(models.RoleAdmin), staffHandler.GetStaff)
+		staffRoutes.PATCH("/:id", auth, requireRoles(models.RoleAdmin), staffHandler.UpdateStaff)
+		staffRoutes.DELETE("/:id", auth, requireRoles(models.RoleAdmin), staffHandler.DeleteStaff)
+		staffRoutes.POST("/:id/deactivate", auth, requireRoles(models.RoleAdmin), staffHandler.DeactivateStaff)
+		staffRoutes.POST("/:id/reactivate", auth, requireRoles(models.RoleAdmin), staffHandler.ReactivateStaff)
+		staffRoutes.GET("/:id/memberships", auth, requireRoles(models.RoleAdmin), staffHandler.ListMemberships)
+		staffRoutes.DELETE("/:id/membership", auth, requireRoles(models.RoleAdmin), staffHandler.RemoveMembership)
+		staffRoutes.GET("/:id/sessions", auth, requireRoles(models.RoleAdmin), staffHandler.ListStaffSessions)
+		staffRoutes.DELETE("/:id/sessions", auth, requireRoles(models.RoleAdmin), staffHandler.RevokeStaffSessions)
+		staffRoutes.DELETE("/:id/sessions/:sid", auth, requireRoles(models.RoleAdmin), staffHandler.RevokeStaffSession)
+		staffRoutes.POST("/:id/unlock", auth, requireRoles(models.RoleAdmin), staffHandler.UnlockStaff)
+		staffRoutes.POST("/:id/password/reset", auth, requireRoles(models.RoleAdmin), staffHandler.RequestPasswordReset)
+		staffRoutes.POST("/password/reset", staffHandler.ResetPassword)
+		staffRoutes.POST("/password/change",
+			middleware.AuthMiddleware(issuer, db, models.TokenPurposePasswordChange), staffHandler.ChangePassword)
+	}
+
+	if oidc != nil {
+		oidcHandler := handlers.NewOIDCHandler(db, issuer, oidc)
+		staffRoutes.GET("/oidc/login", oidcHandler.Login)
+		staffRoutes.GET("/oidc/callback", oidcHandler.Callback)
+	}
+
+	invitationRoutes := staffRoutes.Group("/invitations")
+	invitationRoutes.Use(auth, requireRoles(models.RoleAdmin))
+	{
+		invitationRoutes.POST("", invitationHandler.CreateInvitation)
+		invitationRoutes.GET("", invitationHandler.ListInvitations)
+		invitationRoutes.DELETE("/:id", invitationHandler.RevokeInvitation)
+	}
+
+	// Enrolment also accepts the challenge token handed out by Login when the
+	// hospital requires MFA and the staff member has not enrolled yet.
+	mfaRoutes := staffRoutes.Group("/mfa")
+	{
+		enroll := middleware.AuthMiddleware(issuer, db, models.TokenPurposeMFAEnroll)
+		mfaRoutes.POST("/enroll", enroll, mfaHandler.Enroll)
+		mfaRoutes.POST("/confirm", enroll, mfaHandler.Confirm)
+		mfaRoutes.POST("/disable", auth, mfaHandler.Disable)
+		mfaRoutes.POST("/recovery-codes", auth, mfaHandler.RegenerateRecoveryCodes)
+	}
+
+	hospitalRoutes := api.Group("/hospital")
+	hospitalRoutes.Use(auth, requireRoles(models.RoleAdmin))
+	{
+		hospitalRoutes.PUT("/mfa-policy", mfaHandler.SetHospitalPolicy)
+		hospitalRoutes.PUT("/hn-format", patientHandler.SetHNFormat)
+	}
+
+	serviceAccountRoutes := api.Group("/service-accounts")
+	serviceAccountRoutes.Use(auth, requireRoles(models.RoleAdmin))
+	{
+		serviceAccountRoutes.POST("", serviceAccountHandler.CreateServiceAccount)
+		serviceAccountRoutes.GET("", serviceAccountHandler.ListServiceAccounts)
+		serviceAccountRoutes.DELETE("/:id", serviceAccountHandler.DeactivateServiceAccount)
+		serviceAccountRoutes.POST("/:id/keys", serviceAccountHandler.CreateAPIKey)
+		serviceAccountRoutes.GET("/:id/keys", serviceAccountHandler.ListAPIKeys)
+		serviceAccountRoutes.POST("/:id/keys/:key_id/rotate", serviceAccountHandler.RotateAPIKey)
+		serviceAccountRoutes.DELETE("/:id/keys/:key_id", serviceAccountHandler.RevokeAPIKey)
 	}
 
+	// Patient lookups are also open to service accounts (lab systems,
+	// kiosks) holding an API key with the patient:read scope; writes are
+	// staff only.
 	patientRoutes := api.Group("/patient")
-	patientRoutes.Use(middleware.AuthMiddleware())
+	patientRoutes.Use(middleware.AuthOrAPIKeyMiddleware(issuer, db))
 	{
-		patientRoutes.GET("/search/:id", patientHandler.SearchPatient)
-		patientRoutes.GET("/search", patientHandler.SearchPatients)
+		readPatients := allow(models.ScopePatientRead, patientReaders...)
+		patientRoutes.GET("/search/:id", readPatients, patientHandler.SearchPatient)
+		patientRoutes.GET("/search", readPatients, patientHandler.SearchPatients)
+		patientRoutes.POST("/search", readPatients, patientHandler.SearchPatientsByBody)
+		patientRoutes.GET("/suggest", readPatients, patientHandler.SuggestPatients)
+		patientRoutes.POST("/batch", readPatients, patientHandler.LookupPatients)
+		patientRoutes.POST("", requireRoles(patientWriters...), patientHandler.CreatePatient)
+		patientRoutes.PUT("/:id", requireRoles(patientWriters...), patientHandler.ReplacePatient)
+		patientRoutes.PATCH("/:id", requireRoles(patientWriters...), patientHandler.PatchPatient)
+		patientRoutes.DELETE("/:id", requireRoles(models.RoleAdmin), patientHandler.DeletePatient)
+		patientRoutes.POST("/:id/unmask", requireRoles(patientUnmaskers...), patientHandler.UnmaskPatient)
+		patientRoutes.GET("/:id/access-log", requireRoles(models.RoleAdmin, models.RoleAuditor), patientHandler.ListPatientAccessLogs)
 	}
 
 	return r
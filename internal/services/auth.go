@@ -1,57 +1,162 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"hospital-api/internal/configs"
 	"hospital-api/internal/models"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateJWT(staffID int, hospitalID string) (string, error) {
+const challengeTokenTTL = 5 * time.Minute
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenIssuer signs and validates staff access tokens using the JWT
+// settings from configs.Config. When asymmetric keys are configured new
+// tokens are signed with the current key (RS256 or EdDSA, identified by kid);
+// HS256 tokens signed with JWT_SECRET keep validating while JWTAcceptHMAC is
+// enabled so that existing sessions survive the migration.
+type TokenIssuer struct {
+	secret     []byte
+	acceptHMAC bool
+	keys       []signingKey
+	issuer     string
+	audience   string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenIssuer(cfg configs.Config) (*TokenIssuer, error) {
+	keys, err := parseSigningKeys(cfg.JWTSigningKeys)
+	if err != nil {
+		return nil, err
+	}
+	// Keys are sorted by activation, so the first one is the earliest.
+	if len(keys) > 0 && keys[0].activeFrom.After(time.Now()) {
+		return nil, fmt.Errorf("no JWT_SIGNING_KEYS entry is active yet; the earliest, %q, activates at %s",
+			keys[0].kid, keys[0].activeFrom.Format(time.RFC3339))
+	}
+
+	usesHMAC := len(keys) == 0 || cfg.JWTAcceptHMAC
+	if usesHMAC && cfg.JWTSecret == "" {
+		return nil, fmt.Errorf("JWT_SECRET must not be empty")
+	}
+	if usesHMAC && cfg.JWTSecret == configs.DefaultJWTSecret && !cfg.IsDevelopment() {
+		return nil, fmt.Errorf("JWT_SECRET is set to the insecure default; refusing to start in %q mode", cfg.AppEnv)
+	}
+	if cfg.JWTExpirationInSeconds <= 0 || cfg.JWTRefreshExpirationInSeconds <= 0 {
+		return nil, fmt.Errorf("JWT expirations must be positive")
+	}
+
+	return &TokenIssuer{
+		secret:     []byte(cfg.JWTSecret),
+		acceptHMAC: usesHMAC,
+		keys:       keys,
+		issuer:     cfg.JWTIssuer,
+		audience:   cfg.JWTAudience,
+		accessTTL:  time.Duration(cfg.JWTExpirationInSeconds) * time.Second,
+		refreshTTL: time.Duration(cfg.JWTRefreshExpirationInSeconds) * time.Second,
+	}, nil
+}
+
+func (i *TokenIssuer) AccessTTL() time.Duration {
+	return i.accessTTL
+}
+
+func (i *TokenIssuer) RefreshTTL() time.Duration {
+	return i.refreshTTL
+}
+
+// GenerateJWT issues an access token for the session sessionID.
+func (i *TokenIssuer) GenerateJWT(staffID int, hospitalID string, role models.Role, sessionID string) (string, error) {
+	return i.generate(staffID, hospitalID, role, "", sessionID, i.accessTTL)
+}
+
+// GenerateChallengeToken issues a short-lived token that is only accepted
+// where purpose is explicitly allowed, e.g. to finish an MFA login.
+func (i *TokenIssuer) GenerateChallengeToken(staffID int, hospitalID string, role models.Role, purpose string) (string, error) {
+	return i.generate(staffID, hospitalID, role, purpose, "", challengeTokenTTL)
+}
+
+func (i *TokenIssuer) generate(staffID int, hospitalID string, role models.Role, purpose, sessionID string, ttl time.Duration) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %v", err)
+	}
+
+	now := time.Now()
 	claims := models.JWTClaims{
 		StaffID:    staffID,
 		HospitalID: hospitalID,
+		Role:       role,
+		Purpose:    purpose,
+		SessionID:  sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "hospital-api",
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    i.issuer,
+			Audience:  jwt.ClaimStrings{i.audience},
 			Subject:   fmt.Sprintf("staff:%d", staffID),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return "", fmt.Errorf("JWT_SECRET environment variable not set")
+	if len(i.keys) == 0 {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(i.secret)
 	}
 
-	return token.SignedString([]byte(secret))
-}
-
-func ValidateJWT(tokenString string) (*models.JWTClaims, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return nil, fmt.Errorf("JWT_SECRET environment variable not set")
+	// Once signing keys are configured JWT_SECRET only verifies old tokens.
+	key := i.currentKey(now)
+	if key == nil {
+		return "", fmt.Errorf("no signing key is active at %s", now.Format(time.RFC3339))
 	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
 
-	token, err := jwt.ParseWithClaims(tokenString, &models.JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
+func (i *TokenIssuer) ValidateJWT(tokenString string) (*models.JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &models.JWTClaims{}, i.keyFunc,
+		jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}),
+		jwt.WithIssuer(i.issuer),
+		jwt.WithAudience(i.audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %v", err)
+		return nil, fmt.Errorf("%w: failed to parse token: %v", ErrInvalidToken, err)
 	}
 
 	if claims, ok := token.Claims.(*models.JWTClaims); ok && token.Valid {
 		return claims, nil
 	}
 
-	return nil, fmt.Errorf("invalid token claims")
+	return nil, fmt.Errorf("%w: invalid token claims", ErrInvalidToken)
+}
+
+func (i *TokenIssuer) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if !i.acceptHMAC {
+			return nil, fmt.Errorf("HMAC signed tokens are no longer accepted")
+		}
+		return i.secret, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+		kid, _ := token.Header["kid"].(string)
+		key := i.verificationKey(kid, time.Now())
+		if key == nil {
+			return nil, fmt.Errorf("unknown or retired signing key: %q", kid)
+		}
+		if key.method.Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("signing method %s does not match key %q", token.Method.Alg(), kid)
+		}
+		return key.public, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
 }
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"hospital-api/internal/configs"
+	"hospital-api/internal/models"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestNewTokenIssuerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(c *configs.Config)
+		wantErr bool
+	}{
+		{name: "valid", config: func(c *configs.Config) {}},
+		{name: "empty secret", config: func(c *configs.Config) { c.JWTSecret = "" }, wantErr: true},
+		{name: "default secret in development", config: func(c *configs.Config) { c.JWTSecret = configs.DefaultJWTSecret }},
+		{name: "default secret in production", config: func(c *configs.Config) {
+			c.JWTSecret = configs.DefaultJWTSecret
+			c.AppEnv = "production"
+		}, wantErr: true},
+		{name: "zero access expiration", config: func(c *configs.Config) { c.JWTExpirationInSeconds = 0 }, wantErr: true},
+		{name: "negative refresh expiration", config: func(c *configs.Config) { c.JWTRefreshExpirationInSeconds = -1 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testTokenConfig()
+			tt.config(&cfg)
+			_, err := NewTokenIssuer(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTokenIssuer error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenIssuerUsesConfiguredSettings(t *testing.T) {
+	cfg := testTokenConfig()
+	cfg.JWTIssuer = "issuer-a"
+	cfg.JWTAudience = "audience-a"
+	cfg.JWTExpirationInSeconds = 120
+	issuer, err := NewTokenIssuer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := issuer.GenerateJWT(7, "H001", models.RoleDoctor, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := issuer.ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	if claims.Issuer != "issuer-a" || len(claims.Audience) != 1 || claims.Audience[0] != "audience-a" {
+		t.Errorf("issuer %q, audience %v", claims.Issuer, claims.Audience)
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != 2*time.Minute {
+		t.Errorf("token lifetime = %v, want 2m", ttl)
+	}
+	if claims.StaffID != 7 || claims.HospitalID != "H001" || claims.Role != models.RoleDoctor || claims.SessionID != "session" {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestValidateJWTRejects(t *testing.T) {
+	cfg := testTokenConfig()
+	issuer, err := NewTokenIssuer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func() models.JWTClaims {
+		now := time.Now()
+		return models.JWTClaims{
+			StaffID:    1,
+			HospitalID: "H001",
+			Role:       models.RoleAdmin,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti",
+				Issuer:    cfg.JWTIssuer,
+				Audience:  jwt.ClaimStrings{cfg.JWTAudience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+		}
+	}
+	sign := func(claims models.JWTClaims, secret string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{"other issuer", func() string {
+			c := valid()
+			c.Issuer = "someone-else"
+			return sign(c, cfg.JWTSecret)
+		}},
+		{"no issuer", func() string {
+			c := valid()
+			c.Issuer = ""
+			return sign(c, cfg.JWTSecret)
+		}},
+		{"other audience", func() string {
+			c := valid()
+			c.Audience = jwt.ClaimStrings{"another-api"}
+			return sign(c, cfg.JWTSecret)
+		}},
+		{"no audience", func() string {
+			c := valid()
+			c.Audience = nil
+			return sign(c, cfg.JWTSecret)
+		}},
+		{"expired", func() string {
+			c := valid()
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			return sign(c, cfg.JWTSecret)
+		}},
+		{"no expiry", func() string {
+			c := valid()
+			c.ExpiresAt = nil
+			return sign(c, cfg.JWTSecret)
+		}},
+		{"other secret", func() string { return sign(valid(), "guessed-secret") }},
+		{"unsigned", func() string {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}},
+		{"HS512", func() string {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, valid()).SignedString([]byte(cfg.JWTSecret))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := issuer.ValidateJWT(tt.token()); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("ValidateJWT = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	if _, err := issuer.ValidateJWT(sign(valid(), cfg.JWTSecret)); err != nil {
+		t.Errorf("ValidateJWT of a valid token: %v", err)
+	}
+}
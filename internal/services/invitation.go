@@ -0,0 +1,123 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultInvitationTTL = 72 * time.Hour
+
+var (
+	ErrInvalidInvitation  = errors.New("invitation code is invalid, expired or already used")
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+type InvitationService struct {
+	db *gorm.DB
+}
+
+func NewInvitationService(db *gorm.DB) *InvitationService {
+	return &InvitationService{db: db}
+}
+
+// IssueInvitation creates an invitation for the admin's hospital and returns
+// the plaintext code. The code is never stored and cannot be retrieved again.
+func (s *InvitationService) IssueInvitation(hospitalID string, createdByID uint, role models.Role, ttl time.Duration) (string, *models.StaffInvitation, error) {
+	if !role.IsValid() {
+		return "", nil, fmt.Errorf("invalid role: %s", role)
+	}
+	if ttl <= 0 {
+		ttl = defaultInvitationTTL
+	}
+
+	code, err := generateInvitationCode()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate invitation code: %v", err)
+	}
+
+	invitation := &models.StaffInvitation{
+		CodeHash:    hashInvitationCode(code),
+		HospitalID:  hospitalID,
+		Role:        role,
+		CreatedByID: createdByID,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := s.db.Create(invitation).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to create invitation: %v", err)
+	}
+
+	return code, invitation, nil
+}
+
+func (s *InvitationService) ListInvitations(hospitalID string) ([]models.StaffInvitation, error) {
+	var invitations []models.StaffInvitation
+	if err := s.db.Where("hospital_id = ?", hospitalID).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %v", err)
+	}
+	return invitations, nil
+}
+
+func (s *InvitationService) RevokeInvitation(hospitalID string, id uint) error {
+	result := s.db.Model(&models.StaffInvitation{}).
+		Where("id = ? AND hospital_id = ? AND used_at IS NULL AND revoked_at IS NULL", id, hospitalID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke invitation: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// consumeInvitation marks the invitation as used inside tx. The conditional
+// update guarantees that two concurrent sign-ups cannot share one code.
+func consumeInvitation(tx *gorm.DB, code string) (*models.StaffInvitation, error) {
+	var invitation models.StaffInvitation
+	err := tx.Where("code_hash = ?", hashInvitationCode(code)).First(&invitation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	now := time.Now()
+	if !invitation.IsUsable(now) {
+		return nil, ErrInvalidInvitation
+	}
+
+	result := tx.Model(&models.StaffInvitation{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?", invitation.ID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, fmt.Errorf("database error: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidInvitation
+	}
+
+	invitation.UsedAt = &now
+	return &invitation, nil
+}
+
+func generateInvitationCode() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(b)), nil
+}
+
+func hashInvitationCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"hospital-api/internal/models"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is one asymmetric key of the issuer's key set. Keys take over
+// signing at ActiveFrom; a superseded key keeps verifying until every token
+// it could have signed has expired.
+type signingKey struct {
+	kid        string
+	method     jwt.SigningMethod
+	private    interface{}
+	public     interface{}
+	activeFrom time.Time
+}
+
+// parseSigningKeys reads JWT_SIGNING_KEYS, a comma separated list of
+// "kid=/path/to/key.pem" entries with an optional "@RFC3339" activation
+// time, e.g. "2026-01=/keys/a.pem,2026-07=/keys/b.pem@2026-07-01T00:00:00Z".
+func parseSigningKeys(spec string) ([]signingKey, error) {
+	var keys []signingKey
+	seen := map[string]bool{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, rest, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || rest == "" {
+			return nil, fmt.Errorf("invalid signing key entry %q, expected kid=path[@activation]", entry)
+		}
+		if seen[kid] {
+			return nil, fmt.Errorf("duplicate signing key id %q", kid)
+		}
+		seen[kid] = true
+
+		path, activation, _ := strings.Cut(rest, "@")
+		var activeFrom time.Time
+		if activation != "" {
+			t, err := time.Parse(time.RFC3339, activation)
+			if err != nil {
+				return nil, fmt.Errorf("invalid activation time for key %q: %v", kid, err)
+			}
+			activeFrom = t
+		}
+
+		key, err := loadSigningKey(kid, path)
+		if err != nil {
+			return nil, err
+		}
+		key.activeFrom = activeFrom
+		keys = append(keys, *key)
+	}
+
+	sort.SliceStable(keys, func(a, b int) bool {
+		return keys[a].activeFrom.Before(keys[b].activeFrom)
+	})
+	return keys, nil
+}
+
+func loadSigningKey(kid, path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %q: %v", kid, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %q is not PEM encoded", kid)
+	}
+
+	var private interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("signing key %q has unsupported PEM type %q", kid, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %q: %v", kid, err)
+	}
+
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA signing key %q must be at least 2048 bits", kid)
+		}
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, private: k, public: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, private: k, public: k.Public()}, nil
+	default:
+		return nil, fmt.Errorf("signing key %q must be RSA or Ed25519", kid)
+	}
+}
+
+// currentKey returns the key that signs new tokens at now, or nil when no
+// asymmetric key is active yet.
+func (i *TokenIssuer) currentKey(now time.Time) *signingKey {
+	var current *signingKey
+	for idx := range i.keys {
+		if i.keys[idx].activeFrom.After(now) {
+			break
+		}
+		current = &i.keys[idx]
+	}
+	return current
+}
+
+// isRetired reports whether key was superseded long enough ago that no
+// unexpired token signed by it can still exist.
+func (i *TokenIssuer) isRetired(key *signingKey, now time.Time) bool {
+	for idx := range i.keys {
+		next := &i.keys[idx]
+		if next.kid == key.kid || !next.activeFrom.After(key.activeFrom) || next.activeFrom.After(now) {
+			continue
+		}
+		if now.After(next.activeFrom.Add(i.accessTTL)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *TokenIssuer) verificationKey(kid string, now time.Time) *signingKey {
+	for idx := range i.keys {
+		key := &i.keys[idx]
+		if key.kid == kid && !key.activeFrom.After(now) && !i.isRetired(key, now) {
+			return key
+		}
+	}
+	return nil
+}
+
+// JWKS returns the public keys partners need to verify our tokens: the
+// current key, superseded keys still inside their grace period, and keys
+// scheduled to become active so caches pick them up ahead of rotation.
+func (i *TokenIssuer) JWKS() models.JWKS {
+	now := time.Now()
+	jwks := models.JWKS{Keys: []models.JWK{}}
+
+	for idx := range i.keys {
+		key := &i.keys[idx]
+		if i.isRetired(key, now) {
+			continue
+		}
+
+		jwk := models.JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
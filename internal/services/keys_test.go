@@ -0,0 +1,361 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKeyPEM stores key in dir as name.pem and returns the path.
+func writeKeyPEM(t *testing.T, dir, name string, key interface{}) string {
+	t.Helper()
+	var block *pem.Block
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	path := filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newEd25519PEM(t *testing.T, dir, name string) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeKeyPEM(t, dir, name, key)
+}
+
+func TestParseSigningKeys(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPath := writeKeyPEM(t, dir, "rsa", rsaKey)
+	edPath := newEd25519PEM(t, dir, "ed")
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallPath := writeKeyPEM(t, dir, "small", smallKey)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPath := writeKeyPEM(t, dir, "ec", ecKey)
+	notPEM := filepath.Join(dir, "plain.txt")
+	if err := os.WriteFile(notPEM, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		spec := fmt.Sprintf(" b=%s@2026-07-01T00:00:00Z , a=%s ,", edPath, rsaPath)
+		keys, err := parseSigningKeys(spec)
+		if err != nil {
+			t.Fatalf("parseSigningKeys: %v", err)
+		}
+		if len(keys) != 2 || keys[0].kid != "a" || keys[1].kid != "b" {
+			t.Fatalf("keys = %+v, want a then b", keys)
+		}
+		if keys[0].method != jwt.SigningMethodRS256 || !keys[0].activeFrom.IsZero() {
+			t.Errorf("key a: %s active from %v", keys[0].method.Alg(), keys[0].activeFrom)
+		}
+		if keys[1].method != jwt.SigningMethodEdDSA || !keys[1].activeFrom.Equal(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("key b: %s active from %v", keys[1].method.Alg(), keys[1].activeFrom)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{"missing path", "a=", "expected kid=path"},
+		{"missing kid", "=" + edPath, "expected kid=path"},
+		{"duplicate kid", "a=" + edPath + ",a=" + rsaPath, "duplicate"},
+		{"bad activation", "a=" + edPath + "@tomorrow", "invalid activation"},
+		{"missing file", "a=" + filepath.Join(dir, "missing.pem"), "failed to read"},
+		{"not PEM", "a=" + notPEM, "not PEM"},
+		{"short RSA key", "a=" + smallPath, "at least 2048 bits"},
+		{"EC key", "a=" + ecPath, "must be RSA or Ed25519"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSigningKeys(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseSigningKeys(%q) = %v, want error containing %q", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTokenIssuerRequiresActiveKey(t *testing.T) {
+	dir := t.TempDir()
+	a := newEd25519PEM(t, dir, "a")
+	b := newEd25519PEM(t, dir, "b")
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name    string
+		keys    string
+		wantErr bool
+	}{
+		{"active key", "a=" + a, false},
+		{"active key and scheduled key", "a=" + a + ",b=" + b + "@" + future, false},
+		{"only a scheduled key", "b=" + b + "@" + future, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testTokenConfig()
+			cfg.JWTSigningKeys = tt.keys
+			_, err := NewTokenIssuer(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTokenIssuer error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// rotatingIssuer returns an issuer with a retired, a superseded, a current
+// and a scheduled key, relative to now and an access TTL of 15 minutes.
+func rotatingIssuer(t *testing.T, acceptHMAC bool) *TokenIssuer {
+	t.Helper()
+	dir := t.TempDir()
+	at := func(d time.Duration) string { return time.Now().Add(d).UTC().Format(time.RFC3339) }
+
+	cfg := testTokenConfig()
+	cfg.JWTAcceptHMAC = acceptHMAC
+	cfg.JWTSigningKeys = strings.Join([]string{
+		"retired=" + newEd25519PEM(t, dir, "retired") + "@" + at(-3*time.Hour),
+		"previous=" + newEd25519PEM(t, dir, "previous") + "@" + at(-2*time.Hour),
+		"current=" + newEd25519PEM(t, dir, "current") + "@" + at(-5*time.Minute),
+		"next=" + newEd25519PEM(t, dir, "next") + "@" + at(time.Hour),
+	}, ",")
+	issuer, err := NewTokenIssuer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+// signWithKey signs claims for the issuer with the key kid.
+func signWithKey(t *testing.T, issuer *TokenIssuer, kid string) string {
+	t.Helper()
+	for _, key := range issuer.keys {
+		if key.kid != kid {
+			continue
+		}
+		now := time.Now()
+		token := jwt.NewWithClaims(key.method, models.JWTClaims{
+			StaffID: 1,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti",
+				Issuer:    issuer.issuer,
+				Audience:  jwt.ClaimStrings{issuer.audience},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key.private)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	t.Fatalf("no key %q", kid)
+	return ""
+}
+
+func TestKeyRotation(t *testing.T) {
+	issuer := rotatingIssuer(t, false)
+
+	token, err := issuer.GenerateJWT(1, "H001", models.RoleDoctor, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &models.JWTClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := parsed.Header["kid"]; kid != "current" || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("new token signed with %v/%s, want current/EdDSA", kid, parsed.Method.Alg())
+	}
+
+	tests := []struct {
+		kid   string
+		valid bool
+	}{
+		{"current", true},
+		{"previous", true}, // superseded five minutes ago, inside the access TTL
+		{"retired", false}, // superseded two hours ago
+		{"next", false},    // not active yet
+	}
+	for _, tt := range tests {
+		t.Run(tt.kid, func(t *testing.T) {
+			_, err := issuer.ValidateJWT(signWithKey(t, issuer, tt.kid))
+			if tt.valid && err != nil {
+				t.Errorf("ValidateJWT: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("ValidateJWT = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	t.Run("current key after the next one activates", func(t *testing.T) {
+		later := time.Now().Add(time.Hour + 20*time.Minute)
+		if key := issuer.currentKey(later); key == nil || key.kid != "next" {
+			t.Errorf("currentKey = %v, want next", key)
+		}
+		if key := issuer.verificationKey("current", later); key != nil {
+			t.Error("current key still verifies after its grace period")
+		}
+	})
+}
+
+func TestKeyRotationHMAC(t *testing.T) {
+	hmacToken := func(t *testing.T, issuer *TokenIssuer) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, models.JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    issuer.issuer,
+				Audience:  jwt.ClaimStrings{issuer.audience},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		}).SignedString([]byte(testTokenConfig().JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	t.Run("rejected by default", func(t *testing.T) {
+		issuer := rotatingIssuer(t, false)
+		if _, err := issuer.ValidateJWT(hmacToken(t, issuer)); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("ValidateJWT = %v, want ErrInvalidToken", err)
+		}
+	})
+	t.Run("accepted while migrating", func(t *testing.T) {
+		issuer := rotatingIssuer(t, true)
+		if _, err := issuer.ValidateJWT(hmacToken(t, issuer)); err != nil {
+			t.Errorf("ValidateJWT: %v", err)
+		}
+		// New tokens are still signed with the asymmetric key.
+		token, err := issuer.GenerateJWT(1, "H001", models.RoleDoctor, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed, _, _ := jwt.NewParser().ParseUnverified(token, &models.JWTClaims{}); parsed.Method.Alg() != "EdDSA" {
+			t.Errorf("new token signed with %s, want EdDSA", parsed.Method.Alg())
+		}
+	})
+	t.Run("kid of another algorithm", func(t *testing.T) {
+		issuer := rotatingIssuer(t, false)
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, models.JWTClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    issuer.issuer,
+				Audience:  jwt.ClaimStrings{issuer.audience},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		})
+		token.Header["kid"] = "current"
+		signed, err := token.SignedString(rsaKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := issuer.ValidateJWT(signed); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("ValidateJWT = %v, want ErrInvalidToken", err)
+		}
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	issuer := rotatingIssuer(t, false)
+
+	jwks := issuer.JWKS()
+	var kids []string
+	for _, jwk := range jwks.Keys {
+		kids = append(kids, jwk.Kid)
+		if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != "EdDSA" || jwk.Use != "sig" {
+			t.Errorf("JWK %s = %+v", jwk.Kid, jwk)
+		}
+
+		// The published key must verify what the private key signs.
+		public, err := jwkPublicKey(jwk)
+		if err != nil {
+			t.Fatalf("JWK %s: %v", jwk.Kid, err)
+		}
+		var want ed25519.PublicKey
+		for _, key := range issuer.keys {
+			if key.kid == jwk.Kid {
+				want = key.public.(ed25519.PublicKey)
+			}
+		}
+		if !want.Equal(public) {
+			t.Errorf("JWK %s does not match the signing key", jwk.Kid)
+		}
+	}
+	if got := strings.Join(kids, ","); got != "previous,current,next" {
+		t.Errorf("JWKS kids = %s, want previous,current,next", got)
+	}
+}
+
+func TestJWKSRSA(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testTokenConfig()
+	cfg.JWTSigningKeys = "rsa=" + writeKeyPEM(t, dir, "rsa", rsaKey)
+	issuer, err := NewTokenIssuer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := issuer.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != "RSA" || jwks.Keys[0].Alg != "RS256" {
+		t.Fatalf("JWKS = %+v", jwks)
+	}
+	public, err := jwkPublicKey(jwks.Keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rsaKey.PublicKey.Equal(public) {
+		t.Error("JWK does not match the RSA signing key")
+	}
+
+	// HMAC is off once keys are configured, unless JWT_ACCEPT_HMAC is set.
+	if issuer.acceptHMAC {
+		t.Error("issuer accepts HMAC tokens without JWT_ACCEPT_HMAC")
+	}
+}
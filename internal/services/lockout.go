@@ -0,0 +1,224 @@
+package services
+
+import (
+	"fmt"
+	"hospital-api/internal/configs"
+	"hospital-api/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxLoginDelay = 4 * time.Second
+
+// LoginBlockedError is returned when a login is refused before the password
+// is checked, either because the account is locked or because the client IP
+// exceeded its failure budget.
+type LoginBlockedError struct {
+	Reason string
+	Until  time.Time
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("login blocked (%s) until %s", e.Reason, e.Until.Format(time.RFC3339))
+}
+
+func (e *LoginBlockedError) RetryAfter() time.Duration {
+	if d := time.Until(e.Until); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// loginGuard tracks failed logins per username and per IP, slows down
+// repeated failures and temporarily locks accounts. Usernames without an
+// account are counted and locked the same way in login_lockouts.
+type loginGuard struct {
+	db            *gorm.DB
+	maxFailures   int
+	lockout       time.Duration
+	maxIPFailures int64
+	ipWindow      time.Duration
+	sleep         func(time.Duration)
+}
+
+func newLoginGuard(db *gorm.DB, cfg configs.Config) *loginGuard {
+	return &loginGuard{
+		db:            db,
+		maxFailures:   int(cfg.LoginMaxFailedAttempts),
+		lockout:       time.Duration(cfg.LoginLockoutMinutes) * time.Minute,
+		maxIPFailures: cfg.LoginMaxFailedAttemptsPerIP,
+		ipWindow:      time.Duration(cfg.LoginIPWindowMinutes) * time.Minute,
+		sleep:         time.Sleep,
+	}
+}
+
+// checkIP refuses the attempt when ip has too many recent failures.
+func (g *loginGuard) checkIP(username, ip string) error {
+	if g.maxIPFailures <= 0 || ip == "" {
+		return nil
+	}
+
+	since := time.Now().Add(-g.ipWindow)
+	var failures int64
+	err := g.db.Model(&models.LoginAttempt{}).
+		Where("ip_address = ? AND success = ? AND created_at > ?", ip, false, since).
+		Count(&failures).Error
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	if failures >= g.maxIPFailures {
+		g.record(username, nil, ip, false, models.LoginReasonIPBlocked)
+		return &LoginBlockedError{Reason: models.LoginReasonIPBlocked, Until: time.Now().Add(g.ipWindow)}
+	}
+	return nil
+}
+
+func (g *loginGuard) checkAccount(staff *models.UserStaff, ip string) error {
+	if staff.IsLocked(time.Now()) {
+		g.record(staff.Username, &staff.ID, ip, false, models.LoginReasonAccountLocked)
+		return &LoginBlockedError{Reason: models.LoginReasonAccountLocked, Until: *staff.LockedUntil}
+	}
+	return nil
+}
+
+// checkUsername is checkAccount for a username without an account.
+func (g *loginGuard) checkUsername(username, ip string) error {
+	var lockout models.LoginLockout
+	err := g.db.Where("username = ?", username).Limit(1).Find(&lockout).Error
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if lockout.IsLocked(time.Now()) {
+		g.record(username, nil, ip, false, models.LoginReasonAccountLocked)
+		return &LoginBlockedError{Reason: models.LoginReasonAccountLocked, Until: *lockout.LockedUntil}
+	}
+	return nil
+}
+
+// recordFailure logs the failure, bumps the counter of username and locks
+// it once it reaches the limit. The caller is then delayed progressively so
+// that scripted guessing slows down with every failure. staff is nil when
+// the username has no account.
+func (g *loginGuard) recordFailure(username string, staff *models.UserStaff, ip, reason string) {
+	var staffID *uint
+	var failures int
+	if staff != nil {
+		staffID = &staff.ID
+		failures = g.countAccountFailure(staff)
+	} else {
+		failures = g.countUsernameFailure(username)
+	}
+
+	g.record(username, staffID, ip, false, reason)
+	g.sleep(progressiveDelay(failures))
+}
+
+func (g *loginGuard) countAccountFailure(staff *models.UserStaff) int {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"failed_login_attempts": gorm.Expr("failed_login_attempts + 1"),
+		"last_failed_login_at":  now,
+	}
+	// The count is incremented and read back in one statement so that
+	// concurrent wrong guesses each see their own number; the value loaded
+	// before the password check may already be stale.
+	var counted models.UserStaff
+	err := g.db.Model(&counted).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_login_attempts"}}}).
+		Where("id = ?", staff.ID).
+		Updates(updates).Error
+	if err != nil {
+		log.Printf("Failed to record login failure for staff %d: %v", staff.ID, err)
+		counted.FailedLoginAttempts = staff.FailedLoginAttempts + 1
+	}
+
+	failures := counted.FailedLoginAttempts
+	if g.reachedLimit(failures) {
+		lockedUntil := now.Add(g.lockout)
+		if err := g.db.Model(&models.UserStaff{}).Where("id = ?", staff.ID).Update("locked_until", lockedUntil).Error; err != nil {
+			log.Printf("Failed to lock staff %d: %v", staff.ID, err)
+		}
+		log.Printf("SECURITY: staff '%s' locked until %s after %d failed logins", staff.Username, lockedUntil.Format(time.RFC3339), failures)
+	}
+	return failures
+}
+
+func (g *loginGuard) countUsernameFailure(username string) int {
+	now := time.Now()
+	counted := models.LoginLockout{Username: username, FailedLoginAttempts: 1, LastFailedLoginAt: &now}
+	err := g.db.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "username"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failed_login_attempts": gorm.Expr("login_lockouts.failed_login_attempts + 1"),
+				"last_failed_login_at":  now,
+			}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "failed_login_attempts"}}},
+	).Create(&counted).Error
+	if err != nil {
+		log.Printf("Failed to record login failure for '%s': %v", username, err)
+	}
+
+	failures := counted.FailedLoginAttempts
+	if g.reachedLimit(failures) {
+		lockedUntil := now.Add(g.lockout)
+		if err := g.db.Model(&models.LoginLockout{}).Where("username = ?", username).Update("locked_until", lockedUntil).Error; err != nil {
+			log.Printf("Failed to lock '%s': %v", username, err)
+		}
+		log.Printf("SECURITY: unknown username '%s' locked until %s after %d failed logins", username, lockedUntil.Format(time.RFC3339), failures)
+	}
+	return failures
+}
+
+func (g *loginGuard) reachedLimit(failures int) bool {
+	return g.maxFailures > 0 && failures >= g.maxFailures
+}
+
+func (g *loginGuard) recordSuccess(staff *models.UserStaff, ip string) {
+	if staff.FailedLoginAttempts > 0 || staff.LockedUntil != nil {
+		err := g.db.Model(&models.UserStaff{}).Where("id = ?", staff.ID).Updates(map[string]interface{}{
+			"failed_login_attempts": 0,
+			"locked_until":          nil,
+		}).Error
+		if err != nil {
+			log.Printf("Failed to reset login failures for staff %d: %v", staff.ID, err)
+		}
+	}
+	g.record(staff.Username, &staff.ID, ip, true, models.LoginReasonSuccess)
+}
+
+func (g *loginGuard) record(username string, staffID *uint, ip string, success bool, reason string) {
+	attempt := models.LoginAttempt{
+		Username:  username,
+		StaffID:   staffID,
+		IPAddress: ip,
+		Success:   success,
+		Reason:    reason,
+	}
+	if err := g.db.Create(&attempt).Error; err != nil {
+		log.Printf("Failed to write security log: %v", err)
+	}
+	if !success {
+		log.Printf("SECURITY: failed login for '%s' from %s (%s)", username, ip, reason)
+	}
+}
+
+// progressiveDelay doubles from 250ms with every consecutive failure.
+func progressiveDelay(failures int) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+	delay := 250 * time.Millisecond
+	for i := 1; i < failures && delay < maxLoginDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxLoginDelay {
+		delay = maxLoginDelay
+	}
+	return delay
+}
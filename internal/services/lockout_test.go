@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"hospital-api/internal/configs"
+	"hospital-api/internal/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestProgressiveDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{-1, 0},
+		{0, 0},
+		{1, 250 * time.Millisecond},
+		{2, 500 * time.Millisecond},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 4 * time.Second},
+		{1000, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := progressiveDelay(tt.failures); got != tt.want {
+			t.Errorf("progressiveDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginGuardReachedLimit(t *testing.T) {
+	tests := []struct {
+		maxFailures int
+		failures    int
+		want        bool
+	}{
+		{5, 4, false},
+		{5, 5, true},
+		{5, 6, true},
+		{1, 1, true},
+		{0, 100, false}, // lockout disabled
+	}
+	for _, tt := range tests {
+		g := &loginGuard{maxFailures: tt.maxFailures}
+		if got := g.reachedLimit(tt.failures); got != tt.want {
+			t.Errorf("reachedLimit(%d) with limit %d = %v, want %v", tt.failures, tt.maxFailures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginBlockedErrorRetryAfter(t *testing.T) {
+	err := &LoginBlockedError{Reason: models.LoginReasonAccountLocked, Until: time.Now().Add(time.Minute)}
+	if d := err.RetryAfter(); d <= 55*time.Second || d > time.Minute {
+		t.Errorf("RetryAfter = %v, want about a minute", d)
+	}
+	err.Until = time.Now().Add(-time.Minute)
+	if d := err.RetryAfter(); d != 0 {
+		t.Errorf("RetryAfter of a past lock = %v, want 0", d)
+	}
+}
+
+func TestLoginGuardCheckAccount(t *testing.T) {
+	g := &loginGuard{db: dryRunDB(t)}
+	ptr := func(t time.Time) *time.Time { return &t }
+	future := time.Now().Add(10 * time.Minute)
+
+	tests := []struct {
+		name        string
+		lockedUntil *time.Time
+		blocked     bool
+	}{
+		{"never locked", nil, false},
+		{"lock expired", ptr(time.Now().Add(-time.Second)), false},
+		{"locked", &future, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			staff := &models.UserStaff{ID: 1, Username: "alice", LockedUntil: tt.lockedUntil}
+			err := g.checkAccount(staff, "10.0.0.1")
+			if !tt.blocked {
+				if err != nil {
+					t.Errorf("checkAccount = %v, want nil", err)
+				}
+				return
+			}
+			var blocked *LoginBlockedError
+			if !errors.As(err, &blocked) || blocked.Reason != models.LoginReasonAccountLocked || !blocked.Until.Equal(future) {
+				t.Errorf("checkAccount = %v, want account_locked until %v", err, future)
+			}
+		})
+	}
+}
+
+func TestLoginGuardCheckIPDisabled(t *testing.T) {
+	// Neither case may touch the database, which is nil here.
+	if err := (&loginGuard{maxIPFailures: 0}).checkIP("alice", "10.0.0.1"); err != nil {
+		t.Errorf("checkIP with the IP limit off = %v", err)
+	}
+	if err := (&loginGuard{maxIPFailures: 5}).checkIP("alice", ""); err != nil {
+		t.Errorf("checkIP without an IP = %v", err)
+	}
+}
+
+// newTestLoginGuard returns a guard on db that records the delays it would
+// sleep instead of sleeping.
+func newTestLoginGuard(db *gorm.DB, maxFailures int, maxIPFailures int64) (*loginGuard, *[]time.Duration) {
+	var delays []time.Duration
+	return &loginGuard{
+		db:            db,
+		maxFailures:   maxFailures,
+		lockout:       10 * time.Minute,
+		maxIPFailures: maxIPFailures,
+		ipWindow:      15 * time.Minute,
+		sleep:         func(d time.Duration) { delays = append(delays, d) },
+	}, &delays
+}
+
+// cleanupLoginRecords removes the login attempts and lockouts of usernames
+// before and after the test.
+func cleanupLoginRecords(t *testing.T, db *gorm.DB, usernames ...string) {
+	t.Helper()
+	cleanup := func() {
+		db.Where("username IN ?", usernames).Delete(&models.LoginAttempt{})
+		db.Where("username IN ?", usernames).Delete(&models.LoginLockout{})
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+}
+
+func TestLoginGuardLocksAfterThreshold(t *testing.T) {
+	tests := []struct {
+		name    string
+		account bool
+	}{
+		{"existing account", true},
+		{"unknown username", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			createTestHospitals(t, db, "LOCK1")
+			username := "lockout.threshold"
+			cleanupLoginRecords(t, db, username)
+			g, delays := newTestLoginGuard(db, 3, 0)
+
+			var staff *models.UserStaff
+			if tt.account {
+				staff = createTestStaff(t, db, username, "LOCK1", models.RoleNurse)
+			}
+			check := func() error {
+				if staff == nil {
+					return g.checkUsername(username, "10.0.0.1")
+				}
+				var current models.UserStaff
+				if err := db.First(&current, staff.ID).Error; err != nil {
+					t.Fatal(err)
+				}
+				return g.checkAccount(&current, "10.0.0.1")
+			}
+
+			for i := 0; i < 3; i++ {
+				if err := check(); err != nil {
+					t.Fatalf("check before failure %d = %v, want nil", i+1, err)
+				}
+				g.recordFailure(username, staff, "10.0.0.1", models.LoginReasonBadPassword)
+			}
+
+			err := check()
+			var blocked *LoginBlockedError
+			if !errors.As(err, &blocked) || blocked.Reason != models.LoginReasonAccountLocked {
+				t.Fatalf("check after 3 failures = %v, want account_locked", err)
+			}
+			if d := time.Until(blocked.Until); d < 9*time.Minute || d > 10*time.Minute {
+				t.Errorf("locked for %v, want 10m", d)
+			}
+
+			want := []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second}
+			if len(*delays) != len(want) {
+				t.Fatalf("delays = %v, want %v", *delays, want)
+			}
+			for i := range want {
+				if (*delays)[i] != want[i] {
+					t.Errorf("delays = %v, want %v", *delays, want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestLoginGuardSuccessResetsCounter(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "LOCK1")
+	staff := createTestStaff(t, db, "lockout.reset", "LOCK1", models.RoleNurse)
+	cleanupLoginRecords(t, db, staff.Username)
+	g, delays := newTestLoginGuard(db, 3, 0)
+
+	g.recordFailure(staff.Username, staff, "10.0.0.1", models.LoginReasonBadPassword)
+	g.recordFailure(staff.Username, staff, "10.0.0.1", models.LoginReasonBadPassword)
+	if err := db.First(staff, staff.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	g.recordSuccess(staff, "10.0.0.1")
+
+	// The count starts over, so the next failure neither locks nor waits long.
+	g.recordFailure(staff.Username, staff, "10.0.0.1", models.LoginReasonBadPassword)
+	if err := db.First(staff, staff.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if staff.FailedLoginAttempts != 1 || staff.LockedUntil != nil {
+		t.Errorf("after success and one failure: %d failures, locked until %v", staff.FailedLoginAttempts, staff.LockedUntil)
+	}
+	if last := (*delays)[len(*delays)-1]; last != 250*time.Millisecond {
+		t.Errorf("delay after the reset = %v, want 250ms", last)
+	}
+}
+
+func TestLoginGuardIPBudget(t *testing.T) {
+	db := openTestDB(t)
+	usernames := []string{"lockout.ip.a", "lockout.ip.b", "lockout.ip.c"}
+	cleanupLoginRecords(t, db, usernames...)
+	ip := "203.0.113.77"
+	db.Where("ip_address = ?", ip).Delete(&models.LoginAttempt{})
+	t.Cleanup(func() { db.Where("ip_address = ?", ip).Delete(&models.LoginAttempt{}) })
+	g, _ := newTestLoginGuard(db, 0, 2)
+
+	// Failures against different usernames add up per IP.
+	for _, username := range usernames[:2] {
+		if err := g.checkIP(username, ip); err != nil {
+			t.Fatalf("checkIP before the limit = %v", err)
+		}
+		g.recordFailure(username, nil, ip, models.LoginReasonUnknownUser)
+	}
+
+	var blocked *LoginBlockedError
+	if err := g.checkIP(usernames[2], ip); !errors.As(err, &blocked) || blocked.Reason != models.LoginReasonIPBlocked {
+		t.Errorf("checkIP after the limit = %v, want ip_blocked", err)
+	}
+	if err := g.checkIP(usernames[2], "203.0.113.78"); err != nil {
+		t.Errorf("checkIP from another IP = %v, want nil", err)
+	}
+}
+
+func TestLoginUnknownUsernameLooksLikeWrongPassword(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "LOCK1")
+	hasher, err := NewPasswordHasher(configs.Config{PasswordHashAlgorithm: HashAlgorithmBcrypt, BcryptCost: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hasher.Hash("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	staff := createTestStaff(t, db, "lockout.known", "LOCK1", models.RoleClerk)
+	db.Model(staff).Update("password", hash)
+	cleanupLoginRecords(t, db, "lockout.known", "lockout.unknown")
+
+	for _, username := range []string{"lockout.known", "lockout.unknown"} {
+		t.Run(username, func(t *testing.T) {
+			g, _ := newTestLoginGuard(db, 2, 0)
+			s := &StaffService{db: db, guard: g, hasher: hasher}
+			req := &models.LoginRequest{Username: username, Password: "wrong"}
+
+			for i := 0; i < 2; i++ {
+				_, err := s.LoginByRequest(req, "10.0.0.1")
+				if err == nil || err.Error() != "invalid credentials" {
+					t.Fatalf("login %d = %v, want invalid credentials", i+1, err)
+				}
+			}
+			_, err := s.LoginByRequest(req, "10.0.0.1")
+			var blocked *LoginBlockedError
+			if !errors.As(err, &blocked) || blocked.Reason != models.LoginReasonAccountLocked {
+				t.Errorf("login after the limit = %v, want account_locked", err)
+			}
+		})
+	}
+}
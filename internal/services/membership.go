@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotHospitalMember   = errors.New("staff is not a member of this hospital")
+	ErrMembershipExists    = errors.New("staff is already a member of this hospital")
+	ErrHomeHospital        = errors.New("the home hospital membership cannot be removed")
+	ErrNotHomeHospital     = errors.New("only the staff member's home hospital can change this account")
+	ErrNoPendingMembership = errors.New("no pending membership for this hospital")
+)
+
+type MembershipService struct {
+	db *gorm.DB
+}
+
+func NewMembershipService(db *gorm.DB) *MembershipService {
+	return &MembershipService{db: db}
+}
+
+// ScopeToHospital returns a copy of staff whose HospitalID and Role are
+// taken from its membership in hospitalID, ready to be put into a token. An
+// empty hospitalID selects the home hospital.
+func (s *MembershipService) ScopeToHospital(staff *models.UserStaff, hospitalID string) (*models.UserStaff, error) {
+	return scopeToHospital(s.db, staff, hospitalID)
+}
+
+// ListMemberships returns every hospital the staff member belongs to.
+func (s *MembershipService) ListMemberships(staffID uint) ([]models.StaffHospitalMembership, error) {
+	var memberships []models.StaffHospitalMembership
+	err := s.db.Preload("Hospital").Where("staff_id = ?", staffID).Order("hospital_id ASC").Find(&memberships).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query database: %v", err)
+	}
+	return memberships, nil
+}
+
+// AddMembership invites an existing staff member, found by username, to
+// work in the admin's hospital with the given role. The membership stays
+// pending until the staff member accepts it.
+func (s *MembershipService) AddMembership(hospitalID string, adminID uint, req *models.AddMembershipRequest) (*models.StaffHospitalMembership, error) {
+	var staff models.UserStaff
+	if err := s.db.Where("username = ?", req.Username).First(&staff).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStaffNotFound
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	var count int64
+	err := s.db.Model(&models.StaffHospitalMembership{}).
+		Where("staff_id = ? AND hospital_id = ?", staff.ID, hospitalID).
+		Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	if count > 0 {
+		return nil, ErrMembershipExists
+	}
+
+	membership := &models.StaffHospitalMembership{
+		StaffID:    staff.ID,
+		HospitalID: hospitalID,
+		Role:       req.Role,
+		Status:     models.MembershipPending,
+		Source:     models.MembershipSourceAdmin,
+	}
+	if err := s.db.Create(membership).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrMembershipExists
+		}
+		return nil, fmt.Errorf("failed to create membership: %v", err)
+	}
+
+	log.Printf("SECURITY: admin %d invited staff '%s' to hospital %s as %s", adminID, staff.Username, hospitalID, req.Role)
+	return membership, nil
+}
+
+// AcceptMembership activates the caller's pending membership in hospitalID.
+func (s *MembershipService) AcceptMembership(staffID uint, hospitalID string) (*models.StaffHospitalMembership, error) {
+	result := s.db.Model(&models.StaffHospitalMembership{}).
+		Where("staff_id = ? AND hospital_id = ? AND status = ?", staffID, hospitalID, models.MembershipPending).
+		Update("status", models.MembershipActive)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to accept membership: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNoPendingMembership
+	}
+
+	var membership models.StaffHospitalMembership
+	err := s.db.Preload("Hospital").Where("staff_id = ? AND hospital_id = ?", staffID, hospitalID).First(&membership).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	log.Printf("SECURITY: staff %d accepted membership in hospital %s as %s", staffID, hospitalID, membership.Role)
+	return &membership, nil
+}
+
+// DeclineMembership deletes the caller's pending membership in hospitalID.
+func (s *MembershipService) DeclineMembership(staffID uint, hospitalID string) error {
+	result := s.db.Where("staff_id = ? AND hospital_id = ? AND status = ?", staffID, hospitalID, models.MembershipPending).
+		Delete(&models.StaffHospitalMembership{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to decline membership: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoPendingMembership
+	}
+
+	log.Printf("SECURITY: staff %d declined membership in hospital %s", staffID, hospitalID)
+	return nil
+}
+
+// RemoveMembership takes a staff member out of the admin's hospital and
+// revokes the sessions and refresh tokens scoped to it. The home membership can only go
+// away with the account itself.
+func (s *MembershipService) RemoveMembership(hospitalID string, staffID, adminID uint) error {
+	if staffID == adminID {
+		return ErrCannotModifySelf
+	}
+
+	var staff models.UserStaff
+	if err := s.db.First(&staff, staffID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrStaffNotFound
+		}
+		return fmt.Errorf("database error: %v", err)
+	}
+	if staff.HospitalID == hospitalID {
+		return ErrHomeHospital
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return revokeMembership(tx, staffID, hospitalID)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("SECURITY: admin %d removed staff '%s' from hospital %s", adminID, staff.Username, hospitalID)
+	return nil
+}
+
+func scopeToHospital(db *gorm.DB, staff *models.UserStaff, hospitalID string) (*models.UserStaff, error) {
+	if hospitalID == "" {
+		hospitalID = staff.HospitalID
+	}
+
+	var membership models.StaffHospitalMembership
+	err := db.Where("staff_id = ? AND hospital_id = ? AND status = ?", staff.ID, hospitalID, models.MembershipActive).
+		First(&membership).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotHospitalMember
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	scoped := *staff
+	scoped.HospitalID = membership.HospitalID
+	scoped.Role = membership.Role
+	return &scoped, nil
+}
+
+func createMembership(db *gorm.DB, staffID uint, hospitalID string, role models.Role, source models.MembershipSource) (*models.StaffHospitalMembership, error) {
+	membership := &models.StaffHospitalMembership{
+		StaffID:    staffID,
+		HospitalID: hospitalID,
+		Role:       role,
+		Status:     models.MembershipActive,
+		Source:     source,
+	}
+	if err := db.Create(membership).Error; err != nil {
+		return nil, fmt.Errorf("failed to create membership: %v", err)
+	}
+	return membership, nil
+}
+
+// revokeMembership deletes a membership together with the refresh tokens
+// and sessions scoped to it.
+func revokeMembership(tx *gorm.DB, staffID uint, hospitalID string) error {
+	result := tx.Where("staff_id = ? AND hospital_id = ?", staffID, hospitalID).Delete(&models.StaffHospitalMembership{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove membership: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaffNotFound
+	}
+
+	err := tx.Model(&models.RefreshToken{}).
+		Where("staff_id = ? AND hospital_id = ? AND revoked_at IS NULL", staffID, hospitalID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+	}
+	return revokeSessions(tx, "staff_id = ? AND hospital_id = ?", staffID, hospitalID)
+}
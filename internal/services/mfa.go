@@ -0,0 +1,320 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"hospital-api/internal/configs"
+	"hospital-api/internal/models"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrInvalidMFACode        = errors.New("invalid MFA code")
+	ErrMFANotEnrolled        = errors.New("MFA enrolment has not been started")
+	ErrMFAAlreadyEnabled     = errors.New("MFA is already enabled")
+	ErrMFANotEnabled         = errors.New("MFA is not enabled")
+	ErrMFARequiredByHospital = errors.New("MFA is required by the hospital and cannot be disabled")
+	ErrHospitalNotFound      = errors.New("hospital not found")
+)
+
+type MFAService struct {
+	db     *gorm.DB
+	guard  *loginGuard
+	issuer string
+}
+
+func NewMFAService(db *gorm.DB) *MFAService {
+	return &MFAService{
+		db:     db,
+		guard:  newLoginGuard(db, configs.Envs),
+		issuer: configs.Envs.MFAIssuer,
+	}
+}
+
+// LoginChallenge returns the token purpose the staff member must complete
+// before receiving a full token pair, or "" when no second factor is needed.
+func (s *MFAService) LoginChallenge(staff *models.UserStaff) (string, error) {
+	if staff.MFAEnabled {
+		return models.TokenPurposeMFA, nil
+	}
+
+	var hospital models.Hospital
+	if err := s.db.Select("require_mfa").Where("id = ?", staff.HospitalID).First(&hospital).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("database error: %v", err)
+	}
+	if hospital.RequireMFA {
+		return models.TokenPurposeMFAEnroll, nil
+	}
+	return "", nil
+}
+
+// BeginEnrollment generates a new pending TOTP secret. It replaces any
+// unconfirmed secret from an earlier attempt.
+func (s *MFAService) BeginEnrollment(staffID uint) (*models.MFAEnrollResponse, error) {
+	staff, err := s.getStaff(staffID)
+	if err != nil {
+		return nil, err
+	}
+	if staff.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %v", err)
+	}
+	if err := s.db.Model(staff).Update("mfa_pending_secret", secret).Error; err != nil {
+		return nil, fmt.Errorf("failed to store TOTP secret: %v", err)
+	}
+
+	return &models.MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: totpURI(s.issuer, staff.Username, secret),
+	}, nil
+}
+
+// ConfirmEnrollment activates MFA once the first code from the pending
+// secret checks out and returns a fresh set of recovery codes.
+func (s *MFAService) ConfirmEnrollment(staffID uint, code string) (*models.UserStaff, []string, error) {
+	staff, err := s.getStaff(staffID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if staff.MFAEnabled {
+		return nil, nil, ErrMFAAlreadyEnabled
+	}
+	if staff.MFAPendingSecret == "" {
+		return nil, nil, ErrMFANotEnrolled
+	}
+
+	step, ok := validateTOTP(staff.MFAPendingSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, nil, ErrInvalidMFACode
+	}
+
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(staff).Updates(map[string]interface{}{
+			"mfa_enabled":        true,
+			"mfa_secret":         staff.MFAPendingSecret,
+			"mfa_pending_secret": "",
+			"mfa_last_used_step": step,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to enable MFA: %v", err)
+		}
+
+		codes, err = replaceRecoveryCodes(tx, staff.ID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	staff.MFAEnabled = true
+	staff.MFASecret = staff.MFAPendingSecret
+	staff.MFAPendingSecret = ""
+	staff.MFALastUsedStep = step
+
+	log.Printf("SECURITY: staff '%s' enabled MFA", staff.Username)
+	return staff, codes, nil
+}
+
+// VerifyLogin checks a TOTP or recovery code for the second login step.
+// Failures count towards the account lockout like bad passwords do.
+func (s *MFAService) VerifyLogin(staffID uint, code, ip string) (*models.UserStaff, error) {
+	staff, err := s.getStaff(staffID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyGuardedCode(staff, code, ip); err != nil {
+		return nil, err
+	}
+
+	s.guard.recordSuccess(staff, ip)
+	return staff, nil
+}
+
+// Disable turns MFA off after checking a current code. Wrong codes count
+// towards the account lockout, so a stolen access token cannot be used to
+// guess one.
+func (s *MFAService) Disable(staffID uint, code, ip string) error {
+	staff, err := s.getStaff(staffID)
+	if err != nil {
+		return err
+	}
+	if !staff.MFAEnabled {
+		return ErrMFANotEnabled
+	}
+
+	// Any hospital the staff member works at may require MFA.
+	var requiring int64
+	err = s.db.Model(&models.StaffHospitalMembership{}).
+		Joins("JOIN hospitals ON hospitals.id = staff_hospital_memberships.hospital_id").
+		Where("staff_hospital_memberships.staff_id = ? AND staff_hospital_memberships.status = ? AND hospitals.require_mfa = ?",
+			staff.ID, models.MembershipActive, true).
+		Count(&requiring).Error
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if requiring > 0 {
+		return ErrMFARequiredByHospital
+	}
+
+	if err := s.verifyGuardedCode(staff, code, ip); err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(staff).Updates(map[string]interface{}{
+			"mfa_enabled":        false,
+			"mfa_secret":         "",
+			"mfa_pending_secret": "",
+			"mfa_last_used_step": 0,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to disable MFA: %v", err)
+		}
+		return tx.Where("staff_id = ?", staff.ID).Delete(&models.StaffRecoveryCode{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("SECURITY: staff '%s' disabled MFA", staff.Username)
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates all existing recovery codes. Wrong
+// codes count towards the account lockout as in Disable.
+func (s *MFAService) RegenerateRecoveryCodes(staffID uint, code, ip string) ([]string, error) {
+	staff, err := s.getStaff(staffID)
+	if err != nil {
+		return nil, err
+	}
+	if !staff.MFAEnabled {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.verifyGuardedCode(staff, code, ip); err != nil {
+		return nil, err
+	}
+
+	return replaceRecoveryCodes(s.db, staff.ID)
+}
+
+func (s *MFAService) SetHospitalRequirement(hospitalID string, require bool) error {
+	result := s.db.Model(&models.Hospital{}).Where("id = ?", hospitalID).Update("require_mfa", require)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update hospital: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrHospitalNotFound
+	}
+	return nil
+}
+
+// verifyGuardedCode runs verifyCode behind the login guard: locked accounts
+// are refused and wrong codes are recorded as failures.
+func (s *MFAService) verifyGuardedCode(staff *models.UserStaff, code, ip string) error {
+	if err := s.guard.checkAccount(staff, ip); err != nil {
+		return err
+	}
+	if err := s.verifyCode(staff, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.guard.recordFailure(staff.Username, staff, ip, models.LoginReasonBadMFACode)
+		}
+		return err
+	}
+	return nil
+}
+
+// verifyCode accepts a current TOTP code or an unused recovery code.
+func (s *MFAService) verifyCode(staff *models.UserStaff, code string) error {
+	if !staff.MFAEnabled || staff.MFASecret == "" {
+		return ErrMFANotEnabled
+	}
+
+	if step, ok := validateTOTP(staff.MFASecret, code, time.Now(), staff.MFALastUsedStep); ok {
+		result := s.db.Model(&models.UserStaff{}).
+			Where("id = ? AND mfa_last_used_step < ?", staff.ID, step).
+			Update("mfa_last_used_step", step)
+		if result.Error != nil {
+			return fmt.Errorf("database error: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	result := s.db.Model(&models.StaffRecoveryCode{}).
+		Where("staff_id = ? AND code_hash = ? AND used_at IS NULL", staff.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("database error: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+
+	log.Printf("SECURITY: staff '%s' used a recovery code", staff.Username)
+	return nil
+}
+
+func (s *MFAService) getStaff(staffID uint) (*models.UserStaff, error) {
+	var staff models.UserStaff
+	if err := s.db.First(&staff, staffID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStaffNotFound
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return &staff, nil
+}
+
+func replaceRecoveryCodes(db *gorm.DB, staffID uint) ([]string, error) {
+	if err := db.Where("staff_id = ?", staffID).Delete(&models.StaffRecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.StaffRecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %v", err)
+		}
+		codes = append(codes, code)
+		records = append(records, models.StaffRecoveryCode{StaffID: staffID, CodeHash: hashRecoveryCode(code)})
+	}
+
+	if err := db.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %v", err)
+	}
+	return codes, nil
+}
+
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	return code[:5] + "-" + code[5:10], nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return hashToken(normalized)
+}
@@ -0,0 +1,727 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hospital-api/internal/configs"
+	"hospital-api/internal/models"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateTTL = 10 * time.Minute
+	// oidcKeyRefreshInterval limits JWKS refetches triggered by unknown kids.
+	oidcKeyRefreshInterval = time.Minute
+)
+
+var (
+	ErrOIDCStateInvalid = errors.New("OIDC login state is invalid or expired")
+	ErrOIDCLoginFailed  = errors.New("OIDC login failed")
+	ErrOIDCNoRole       = errors.New("identity provider did not grant a role mapped to this API")
+	ErrOIDCNoHospital   = errors.New("identity provider did not grant access to a known hospital")
+	ErrExternalAccount  = errors.New("account is managed by the identity provider")
+)
+
+type oidcMapping struct {
+	from string
+	to   string
+}
+
+// OIDCConfig is the validated single sign-on configuration.
+type OIDCConfig struct {
+	IssuerURL        string
+	AuthorizationURL string // overrides the discovered endpoint, e.g. when the browser sees another host
+	ClientID         string
+	ClientSecret     string
+	RedirectURL      string
+	Scopes           []string
+	UsernameClaim    string
+	RoleClaim        string
+	HospitalClaim    string
+	// RoleMapping is checked in configuration order, the first IdP value
+	// the user holds decides the role.
+	RoleMapping     []oidcMapping
+	DefaultRole     models.Role
+	HospitalMapping []oidcMapping
+}
+
+// NewOIDCConfig returns nil when OIDC_ISSUER_URL is not set, which disables
+// single sign-on.
+func NewOIDCConfig(cfg configs.Config) (*OIDCConfig, error) {
+	if cfg.OIDCIssuerURL == "" {
+		return nil, nil
+	}
+	if cfg.OIDCClientID == "" || cfg.OIDCRedirectURL == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER_URL is set")
+	}
+
+	roleMapping, err := parseOIDCMappings(cfg.OIDCRoleMapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING: %v", err)
+	}
+	for _, m := range roleMapping {
+		if !models.Role(m.to).IsValid() {
+			return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING: unknown role %q", m.to)
+		}
+	}
+	defaultRole := models.Role(cfg.OIDCDefaultRole)
+	if defaultRole != "" && !defaultRole.IsValid() {
+		return nil, fmt.Errorf("invalid OIDC_DEFAULT_ROLE %q", cfg.OIDCDefaultRole)
+	}
+
+	hospitalMapping, err := parseOIDCMappings(cfg.OIDCHospitalMapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC_HOSPITAL_MAPPING: %v", err)
+	}
+
+	scopes := cfg.OIDCScopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	if !containsString(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	return &OIDCConfig{
+		IssuerURL:        strings.TrimSuffix(cfg.OIDCIssuerURL, "/"),
+		AuthorizationURL: cfg.OIDCAuthorizationURL,
+		ClientID:         cfg.OIDCClientID,
+		ClientSecret:     cfg.OIDCClientSecret,
+		RedirectURL:      cfg.OIDCRedirectURL,
+		Scopes:           scopes,
+		UsernameClaim:    cfg.OIDCUsernameClaim,
+		RoleClaim:        cfg.OIDCRoleClaim,
+		HospitalClaim:    cfg.OIDCHospitalClaim,
+		RoleMapping:      roleMapping,
+		DefaultRole:      defaultRole,
+		HospitalMapping:  hospitalMapping,
+	}, nil
+}
+
+// parseOIDCMappings parses "idp_value=our_value" items.
+func parseOIDCMappings(items []string) ([]oidcMapping, error) {
+	mappings := make([]oidcMapping, 0, len(items))
+	for _, item := range items {
+		from, to, ok := strings.Cut(item, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("expected idp_value=value, got %q", item)
+		}
+		mappings = append(mappings, oidcMapping{from: from, to: to})
+	}
+	return mappings, nil
+}
+
+type OIDCService struct {
+	db       *gorm.DB
+	cfg      *OIDCConfig
+	provider *oidcProvider
+}
+
+func NewOIDCService(db *gorm.DB, cfg *OIDCConfig) *OIDCService {
+	return &OIDCService{
+		db:  db,
+		cfg: cfg,
+		provider: &oidcProvider{
+			cfg:    cfg,
+			client: &http.Client{Timeout: 10 * time.Second},
+			keys:   map[string]interface{}{},
+		},
+	}
+}
+
+// AuthorizationURL starts a login: it stores a fresh state, nonce and PKCE
+// verifier and returns the identity provider URL to send the browser to.
+// hospitalID optionally picks the hospital the resulting token is scoped to.
+func (s *OIDCService) AuthorizationURL(ctx context.Context, hospitalID string) (string, error) {
+	discovery, err := s.provider.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate state: %v", err)
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code verifier: %v", err)
+	}
+
+	// Expired states of abandoned logins are cleaned up opportunistically.
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{}).Error; err != nil {
+		log.Printf("Failed to clean up OIDC login states: %v", err)
+	}
+
+	record := &models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		HospitalID:   hospitalID,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return "", fmt.Errorf("failed to store OIDC login state: %v", err)
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {s.cfg.ClientID},
+		"redirect_uri":          {s.cfg.RedirectURL},
+		"scope":                 {strings.Join(s.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	endpoint := discovery.AuthorizationEndpoint
+	if s.cfg.AuthorizationURL != "" {
+		endpoint = s.cfg.AuthorizationURL
+	}
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + query.Encode(), nil
+}
+
+// Callback finishes a login: it consumes the state, exchanges the code,
+// verifies the ID token and provisions or updates the staff account. The
+// returned staff member is scoped to the hospital chosen for the login.
+func (s *OIDCService) Callback(ctx context.Context, code, state string) (*models.UserStaff, error) {
+	loginState, err := s.consumeState(state)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := s.provider.exchangeCode(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.provider.verifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.provision(claims, loginState.HospitalID)
+}
+
+func (s *OIDCService) consumeState(state string) (*models.OIDCLoginState, error) {
+	var record models.OIDCLoginState
+	err := s.db.Where("state_hash = ?", hashToken(state)).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOIDCStateInvalid
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	result := s.db.Delete(&models.OIDCLoginState{}, record.ID)
+	if result.Error != nil {
+		return nil, fmt.Errorf("database error: %v", result.Error)
+	}
+	if result.RowsAffected == 0 || time.Now().After(record.ExpiresAt) {
+		return nil, ErrOIDCStateInvalid
+	}
+	return &record, nil
+}
+
+// provision finds the account linked to the IdP subject, creating it on the
+// first login, and brings its role and hospital memberships in line with
+// the IdP claims. Memberships the IdP granted earlier but no longer claims
+// are revoked together with their sessions; memberships added by admins are
+// left alone.
+func (s *OIDCService) provision(claims jwt.MapClaims, requestedHospital string) (*models.UserStaff, error) {
+	subject, _ := claims["sub"].(string)
+	issuer, _ := claims["iss"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: ID token has no subject", ErrOIDCLoginFailed)
+	}
+	externalID := issuer + "|" + subject
+
+	role := s.mapRole(claims)
+	if role == "" {
+		return nil, ErrOIDCNoRole
+	}
+
+	hospitals, err := s.mapHospitals(claims)
+	if err != nil {
+		return nil, err
+	}
+	if len(hospitals) == 0 {
+		return nil, ErrOIDCNoHospital
+	}
+	if requestedHospital != "" && !containsString(hospitals, requestedHospital) {
+		return nil, ErrNotHospitalMember
+	}
+
+	var staff models.UserStaff
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("external_id = ?", externalID).First(&staff).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			created, err := s.createAccount(tx, claims, externalID, role, hospitals[0])
+			if err != nil {
+				return err
+			}
+			staff = *created
+		case err != nil:
+			return fmt.Errorf("database error: %v", err)
+		case staff.DeletedAt.Valid:
+			return ErrStaffInactive
+		}
+
+		if !staff.IsActive {
+			return ErrStaffInactive
+		}
+
+		for _, hospitalID := range hospitals {
+			if err := syncMembership(tx, staff.ID, hospitalID, role); err != nil {
+				return err
+			}
+		}
+		revoked, err := revokeDroppedMemberships(tx, &staff, hospitals)
+		if err != nil {
+			return err
+		}
+
+		// A home hospital the IdP no longer grants hands over to the first
+		// one it does.
+		home := staff.HospitalID
+		if containsString(revoked, home) {
+			home = hospitals[0]
+		}
+		if containsString(hospitals, home) && (home != staff.HospitalID || staff.Role != role) {
+			err := tx.Model(&staff).Updates(map[string]interface{}{"hospital_id": home, "role": role}).Error
+			if err != nil {
+				return fmt.Errorf("failed to update staff role: %v", err)
+			}
+			staff.HospitalID = home
+			staff.Role = role
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return scopeToHospital(s.db, &staff, requestedHospital)
+}
+
+func (s *OIDCService) createAccount(tx *gorm.DB, claims jwt.MapClaims, externalID string, role models.Role, hospitalID string) (*models.UserStaff, error) {
+	username := firstClaimString(claims, s.cfg.UsernameClaim)
+	if username == "" {
+		username = firstClaimString(claims, "email")
+	}
+	if username == "" {
+		username, _ = claims["sub"].(string)
+	}
+
+	// SSO accounts are never linked to existing local accounts by name.
+	var count int64
+	if err := tx.Unscoped().Model(&models.UserStaff{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	if count > 0 {
+		return nil, ErrUsernameTaken
+	}
+
+	staff := &models.UserStaff{
+		Username:     username,
+		Role:         role,
+		HospitalID:   hospitalID,
+		AuthProvider: models.AuthProviderOIDC,
+		ExternalID:   &externalID,
+	}
+	if err := tx.Create(staff).Error; err != nil {
+		return nil, fmt.Errorf("failed to create staff: %v", err)
+	}
+
+	log.Printf("SECURITY: provisioned SSO staff '%s' in hospital %s as %s", staff.Username, hospitalID, role)
+	return staff, nil
+}
+
+func (s *OIDCService) mapRole(claims jwt.MapClaims) models.Role {
+	values := claimStrings(claims, s.cfg.RoleClaim)
+	for _, m := range s.cfg.RoleMapping {
+		if containsString(values, m.from) {
+			return models.Role(m.to)
+		}
+	}
+	return s.cfg.DefaultRole
+}
+
+// mapHospitals translates the hospital claim and drops hospitals that do
+// not exist here.
+func (s *OIDCService) mapHospitals(claims jwt.MapClaims) ([]string, error) {
+	var candidates []string
+	for _, value := range claimStrings(claims, s.cfg.HospitalClaim) {
+		if len(s.cfg.HospitalMapping) == 0 {
+			candidates = append(candidates, value)
+			continue
+		}
+		for _, m := range s.cfg.HospitalMapping {
+			if m.from == value && !containsString(candidates, m.to) {
+				candidates = append(candidates, m.to)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	var existing []string
+	if err := s.db.Model(&models.Hospital{}).Where("id IN ?", candidates).Pluck("id", &existing).Error; err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	// Keep the IdP order so the first hospital stays the default.
+	hospitals := make([]string, 0, len(existing))
+	for _, id := range candidates {
+		if containsString(existing, id) {
+			hospitals = append(hospitals, id)
+		}
+	}
+	return hospitals, nil
+}
+
+func syncMembership(tx *gorm.DB, staffID uint, hospitalID string, role models.Role) error {
+	var membership models.StaffHospitalMembership
+	err := tx.Where("staff_id = ? AND hospital_id = ?", staffID, hospitalID).First(&membership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_, err = createMembership(tx, staffID, hospitalID, role, models.MembershipSourceIdP)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	// The IdP's assertion counts as acceptance of a pending membership.
+	if membership.Role == role && membership.Status == models.MembershipActive {
+		return nil
+	}
+	err = tx.Model(&membership).Updates(map[string]interface{}{"role": role, "status": models.MembershipActive}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update membership: %v", err)
+	}
+	return nil
+}
+
+// revokeDroppedMemberships revokes the IdP-granted memberships of staff in
+// hospitals the IdP no longer claims and returns those hospitals.
+func revokeDroppedMemberships(tx *gorm.DB, staff *models.UserStaff, hospitals []string) ([]string, error) {
+	var dropped []string
+	err := tx.Model(&models.StaffHospitalMembership{}).
+		Where("staff_id = ? AND source = ? AND hospital_id NOT IN ?", staff.ID, models.MembershipSourceIdP, hospitals).
+		Pluck("hospital_id", &dropped).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	for _, hospitalID := range dropped {
+		if err := revokeMembership(tx, staff.ID, hospitalID); err != nil {
+			return nil, err
+		}
+		log.Printf("SECURITY: identity provider no longer grants staff '%s' hospital %s; membership revoked", staff.Username, hospitalID)
+	}
+	return dropped, nil
+}
+
+// claimStrings reads a string or string array claim. Dotted names reach
+// into nested objects, e.g. "realm_access.roles".
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	var value interface{} = map[string]interface{}(claims)
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func firstClaimString(claims jwt.MapClaims, name string) string {
+	if values := claimStrings(claims, name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider talks to the identity provider and caches its discovery
+// document and signing keys.
+type oidcProvider struct {
+	cfg    *OIDCConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(ctx, p.cfg.IssuerURL+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %v", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.cfg.IssuerURL {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %q, expected %q", discovery.Issuer, p.cfg.IssuerURL)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document is incomplete")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// exchangeCode redeems the authorization code and returns the raw ID token.
+func (p *oidcProvider) exchangeCode(ctx context.Context, code, verifier string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: token request failed: %v", ErrOIDCLoginFailed, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("%w: reading token response: %v", ErrOIDCLoginFailed, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: token endpoint returned %d: %s", ErrOIDCLoginFailed, resp.StatusCode, body)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil || tokens.IDToken == "" {
+		return "", fmt.Errorf("%w: token response has no id_token", ErrOIDCLoginFailed)
+	}
+	return tokens.IDToken, nil
+}
+
+func (p *oidcProvider) verifyIDToken(ctx context.Context, raw, nonce string) (jwt.MapClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.publicKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ID token: %v", ErrOIDCLoginFailed, err)
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("%w: ID token nonce mismatch", ErrOIDCLoginFailed)
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: ID token was issued to %q", ErrOIDCLoginFailed, azp)
+	}
+	return claims, nil
+}
+
+// publicKey returns the provider key for kid, refetching the JWKS when the
+// provider has rotated to a key we have not seen.
+func (p *oidcProvider) publicKey(ctx context.Context, kid string) (interface{}, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < oidcKeyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set models.JWKS
+	if err := p.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %v", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwkPublicKey(jwk)
+		if err != nil {
+			log.Printf("Skipping OIDC signing key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey must be called with p.mu held. A token without kid is accepted
+// only while the provider publishes a single key.
+func (p *oidcProvider) lookupKey(kid string) interface{} {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+func jwkPublicKey(jwk models.JWK) (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %v", err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %v", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %v", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
@@ -0,0 +1,486 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hospital-api/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testOIDCClientID    = "hospital-api"
+	testOIDCRedirectURL = "https://api.example.com/api/v1/staff/oidc/callback"
+	testOIDCCode        = "code-from-idp"
+)
+
+// fakeIdP is an OpenID provider that signs ID tokens with an Ed25519 key
+// and redeems one authorization code, testOIDCCode.
+type fakeIdP struct {
+	*httptest.Server
+	key ed25519.PrivateKey
+
+	mu sync.Mutex
+	// discovery overrides the discovery document when set.
+	discovery map[string]interface{}
+	// idToken is what the token endpoint returns for testOIDCCode.
+	idToken string
+	// form is the last token request.
+	form url.Values
+	// clientID and clientSecret are the last token request's credentials.
+	clientID, clientSecret string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		discovery := idp.discovery
+		if discovery == nil {
+			discovery = map[string]interface{}{
+				"issuer":                 idp.URL,
+				"authorization_endpoint": idp.URL + "/authorize",
+				"token_endpoint":         idp.URL + "/token",
+				"jwks_uri":               idp.URL + "/jwks",
+			}
+		}
+		json.NewEncoder(w).Encode(discovery)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		idp.form = r.PostForm
+		idp.clientID, idp.clientSecret, _ = r.BasicAuth()
+		if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != testOIDCCode {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "id_token": idp.idToken})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.JWKS{Keys: []models.JWK{{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			Kid: "idp-key",
+			Use: "sig",
+			Alg: "EdDSA",
+			X:   base64.RawURLEncoding.EncodeToString(idp.key.Public().(ed25519.PublicKey)),
+		}}})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// claims returns valid ID token claims for subject and nonce.
+func (idp *fakeIdP) claims(subject, nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   idp.URL,
+		"sub":   subject,
+		"aud":   testOIDCClientID,
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"iat":   now.Unix(),
+		"nonce": nonce,
+	}
+}
+
+func (idp *fakeIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = "idp-key"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// issue makes the token endpoint return idToken for testOIDCCode.
+func (idp *fakeIdP) issue(idToken string) {
+	idp.mu.Lock()
+	idp.idToken = idToken
+	idp.mu.Unlock()
+}
+
+// tokenRequest returns the form and client credentials of the last token
+// request.
+func (idp *fakeIdP) tokenRequest() (form url.Values, clientID, clientSecret string) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.form, idp.clientID, idp.clientSecret
+}
+
+func (idp *fakeIdP) config() *OIDCConfig {
+	return &OIDCConfig{
+		IssuerURL:     idp.URL,
+		ClientID:      testOIDCClientID,
+		ClientSecret:  "client-secret",
+		RedirectURL:   testOIDCRedirectURL,
+		Scopes:        []string{"openid"},
+		UsernameClaim: "preferred_username",
+		RoleClaim:     "roles",
+		HospitalClaim: "hospitals",
+		RoleMapping:   []oidcMapping{{from: "physician", to: "doctor"}, {from: "registrar", to: "clerk"}},
+	}
+}
+
+func newTestOIDCProvider(idp *fakeIdP) *oidcProvider {
+	return &oidcProvider{cfg: idp.config(), client: idp.Client(), keys: map[string]interface{}{}}
+}
+
+func TestOIDCProviderDiscover(t *testing.T) {
+	idp := newFakeIdP(t)
+
+	tests := []struct {
+		name      string
+		discovery map[string]interface{}
+		wantErr   bool
+	}{
+		{"valid", nil, false},
+		{"issuer with trailing slash", map[string]interface{}{
+			"issuer":                 idp.URL + "/",
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		}, false},
+		{"other issuer", map[string]interface{}{
+			"issuer":                 "https://evil.example.com",
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		}, true},
+		{"no token endpoint", map[string]interface{}{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"jwks_uri":               idp.URL + "/jwks",
+		}, true},
+		{"no jwks", map[string]interface{}{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.mu.Lock()
+			idp.discovery = tt.discovery
+			idp.mu.Unlock()
+
+			got, err := newTestOIDCProvider(idp).discover(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("discover = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("discover: %v", err)
+			}
+			if got.TokenEndpoint != idp.URL+"/token" || got.JWKSURI != idp.URL+"/jwks" {
+				t.Errorf("discover = %+v", got)
+			}
+		})
+	}
+}
+
+func TestOIDCProviderDiscoverUnreachable(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := newTestOIDCProvider(idp)
+	p.cfg.IssuerURL = idp.URL + "/missing"
+	if _, err := p.discover(context.Background()); err == nil {
+		t.Error("discover of a missing document succeeded")
+	}
+}
+
+func TestOIDCProviderExchangeCode(t *testing.T) {
+	idp := newFakeIdP(t)
+	idToken := idp.sign(t, idp.claims("alice", "n"))
+	idp.issue(idToken)
+
+	t.Run("confidential client", func(t *testing.T) {
+		p := newTestOIDCProvider(idp)
+		got, err := p.exchangeCode(context.Background(), testOIDCCode, "the-verifier")
+		if err != nil {
+			t.Fatalf("exchangeCode: %v", err)
+		}
+		if got != idToken {
+			t.Errorf("exchangeCode = %q, want the ID token", got)
+		}
+		form, clientID, clientSecret := idp.tokenRequest()
+		if form.Get("code_verifier") != "the-verifier" || form.Get("redirect_uri") != testOIDCRedirectURL {
+			t.Errorf("token request = %v", form)
+		}
+		if clientID != testOIDCClientID || clientSecret != "client-secret" || form.Has("client_id") {
+			t.Errorf("client authentication = %q:%q, form client_id %q", clientID, clientSecret, form.Get("client_id"))
+		}
+	})
+
+	t.Run("public client", func(t *testing.T) {
+		p := newTestOIDCProvider(idp)
+		p.cfg.ClientSecret = ""
+		if _, err := p.exchangeCode(context.Background(), testOIDCCode, "the-verifier"); err != nil {
+			t.Fatalf("exchangeCode: %v", err)
+		}
+		form, clientID, _ := idp.tokenRequest()
+		if clientID != "" || form.Get("client_id") != testOIDCClientID {
+			t.Errorf("public client sent basic auth %q, form client_id %q", clientID, form.Get("client_id"))
+		}
+	})
+
+	t.Run("rejected code", func(t *testing.T) {
+		p := newTestOIDCProvider(idp)
+		if _, err := p.exchangeCode(context.Background(), "wrong", "the-verifier"); !errors.Is(err, ErrOIDCLoginFailed) {
+			t.Errorf("exchangeCode = %v, want ErrOIDCLoginFailed", err)
+		}
+	})
+
+	t.Run("no ID token", func(t *testing.T) {
+		idp.issue("")
+		p := newTestOIDCProvider(idp)
+		if _, err := p.exchangeCode(context.Background(), testOIDCCode, "the-verifier"); !errors.Is(err, ErrOIDCLoginFailed) {
+			t.Errorf("exchangeCode = %v, want ErrOIDCLoginFailed", err)
+		}
+	})
+}
+
+func TestOIDCProviderVerifyIDToken(t *testing.T) {
+	idp := newFakeIdP(t)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		claims func(c jwt.MapClaims)
+		sign   func(c jwt.MapClaims) string
+		ok     bool
+	}{
+		{name: "valid", ok: true},
+		{name: "azp is the client", claims: func(c jwt.MapClaims) { c["azp"] = testOIDCClientID }, ok: true},
+		{name: "audience list", claims: func(c jwt.MapClaims) { c["aud"] = []string{"other", testOIDCClientID} }, ok: true},
+		{name: "nonce mismatch", claims: func(c jwt.MapClaims) { c["nonce"] = "replayed" }},
+		{name: "no nonce", claims: func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{name: "other audience", claims: func(c jwt.MapClaims) { c["aud"] = "another-app" }},
+		{name: "azp is another client", claims: func(c jwt.MapClaims) { c["aud"] = []string{testOIDCClientID, "another-app"}; c["azp"] = "another-app" }},
+		{name: "other issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "no expiry", claims: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "unknown key", sign: func(c jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, c)
+			token.Header["kid"] = "idp-key"
+			signed, _ := token.SignedString(otherKey)
+			return signed
+		}},
+		{name: "HMAC with the client ID", sign: func(c jwt.MapClaims) string {
+			signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(testOIDCClientID))
+			return signed
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.claims("alice", "expected-nonce")
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			raw := ""
+			if tt.sign != nil {
+				raw = tt.sign(claims)
+			} else {
+				raw = idp.sign(t, claims)
+			}
+
+			got, err := newTestOIDCProvider(idp).verifyIDToken(context.Background(), raw, "expected-nonce")
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("verifyIDToken: %v", err)
+				}
+				if got["sub"] != "alice" {
+					t.Errorf("sub = %v, want alice", got["sub"])
+				}
+				return
+			}
+			if !errors.Is(err, ErrOIDCLoginFailed) {
+				t.Errorf("verifyIDToken = %v, want ErrOIDCLoginFailed", err)
+			}
+		})
+	}
+}
+
+// oidcLogin runs a login through AuthorizationURL and Callback, with the
+// IdP asserting extra on top of the standard claims.
+func oidcLogin(t *testing.T, s *OIDCService, idp *fakeIdP, subject string, extra jwt.MapClaims) (*models.UserStaff, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, err := s.AuthorizationURL(ctx, "")
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+
+	claims := idp.claims(subject, query.Get("nonce"))
+	for name, value := range extra {
+		claims[name] = value
+	}
+	idp.issue(idp.sign(t, claims))
+
+	staff, err := s.Callback(ctx, testOIDCCode, query.Get("state"))
+
+	// The code verifier sent to the IdP must match the challenge.
+	form, _, _ := idp.tokenRequest()
+	challenge := sha256.Sum256([]byte(form.Get("code_verifier")))
+	if got := base64.RawURLEncoding.EncodeToString(challenge[:]); got != query.Get("code_challenge") {
+		t.Errorf("code_verifier does not match code_challenge %q", query.Get("code_challenge"))
+	}
+	return staff, err
+}
+
+func TestOIDCCallbackRejectsUnknownState(t *testing.T) {
+	db := openTestDB(t)
+	idp := newFakeIdP(t)
+	s := NewOIDCService(db, idp.config())
+	s.provider.client = idp.Client()
+
+	if _, err := s.Callback(context.Background(), testOIDCCode, "never-issued"); !errors.Is(err, ErrOIDCStateInvalid) {
+		t.Errorf("Callback = %v, want ErrOIDCStateInvalid", err)
+	}
+}
+
+func TestOIDCProvisioning(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "OIDC1", "OIDC2", "OIDC3")
+	idp := newFakeIdP(t)
+	s := NewOIDCService(db, idp.config())
+	s.provider.client = idp.Client()
+
+	staff, err := oidcLogin(t, s, idp, "sub-alice", jwt.MapClaims{
+		"preferred_username": "oidc.alice",
+		"roles":              []string{"physician"},
+		"hospitals":          []string{"OIDC1", "OIDC2", "UNKNOWN"},
+	})
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if staff.Username != "oidc.alice" || staff.HospitalID != "OIDC1" || staff.Role != models.RoleDoctor || staff.AuthProvider != models.AuthProviderOIDC {
+		t.Fatalf("provisioned %+v", staff)
+	}
+
+	sources := func() map[string]models.MembershipSource {
+		var memberships []models.StaffHospitalMembership
+		if err := db.Where("staff_id = ?", staff.ID).Find(&memberships).Error; err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]models.MembershipSource, len(memberships))
+		for _, m := range memberships {
+			got[m.HospitalID] = m.Source
+		}
+		return got
+	}
+	if got := sources(); len(got) != 2 || got["OIDC1"] != models.MembershipSourceIdP || got["OIDC2"] != models.MembershipSourceIdP {
+		t.Fatalf("memberships after first login = %v", got)
+	}
+
+	// An admin adds OIDC3 by hand, and sessions are open in OIDC1 and OIDC2.
+	if _, err := createMembership(db, staff.ID, "OIDC3", models.RoleNurse, models.MembershipSourceAdmin); err != nil {
+		t.Fatal(err)
+	}
+	for _, hospitalID := range []string{"OIDC1", "OIDC2"} {
+		family := "family-" + hospitalID
+		if err := db.Create(&models.StaffSession{ID: "session-" + hospitalID, StaffID: staff.ID, HospitalID: hospitalID, FamilyID: family, ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&models.RefreshToken{TokenHash: hashToken(family), FamilyID: family, StaffID: staff.ID, HospitalID: hospitalID, ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The IdP drops OIDC1, the home hospital, and demotes the role.
+	staff, err = oidcLogin(t, s, idp, "sub-alice", jwt.MapClaims{
+		"preferred_username": "oidc.alice",
+		"roles":              []string{"registrar"},
+		"hospitals":          []string{"OIDC2"},
+	})
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if staff.HospitalID != "OIDC2" || staff.Role != models.RoleClerk {
+		t.Errorf("second login scoped to %s as %s, want OIDC2 as clerk", staff.HospitalID, staff.Role)
+	}
+	if got := sources(); len(got) != 2 || got["OIDC2"] != models.MembershipSourceIdP || got["OIDC3"] != models.MembershipSourceAdmin {
+		t.Errorf("memberships after second login = %v, want OIDC2 from the IdP and OIDC3 from an admin", got)
+	}
+
+	var stored models.UserStaff
+	if err := db.First(&stored, staff.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.HospitalID != "OIDC2" || stored.Role != models.RoleClerk {
+		t.Errorf("home membership = %s as %s, want OIDC2 as clerk", stored.HospitalID, stored.Role)
+	}
+
+	for hospitalID, wantRevoked := range map[string]bool{"OIDC1": true, "OIDC2": false} {
+		var session models.StaffSession
+		if err := db.First(&session, "id = ?", "session-"+hospitalID).Error; err != nil {
+			t.Fatal(err)
+		}
+		var token models.RefreshToken
+		if err := db.First(&token, "family_id = ?", "family-"+hospitalID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if (session.RevokedAt != nil) != wantRevoked || (token.RevokedAt != nil) != wantRevoked {
+			t.Errorf("%s: session revoked %v, refresh token revoked %v, want %v", hospitalID, session.RevokedAt != nil, token.RevokedAt != nil, wantRevoked)
+		}
+	}
+}
+
+func TestOIDCProvisioningRefusesLocalUsername(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "OIDC1")
+	idp := newFakeIdP(t)
+	s := NewOIDCService(db, idp.config())
+	s.provider.client = idp.Client()
+
+	local := &models.UserStaff{Username: "oidc.bob", Password: "x", Role: models.RoleNurse, HospitalID: "OIDC1"}
+	if err := db.Create(local).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := oidcLogin(t, s, idp, "sub-bob", jwt.MapClaims{
+		"preferred_username": "oidc.bob",
+		"roles":              []string{"physician"},
+		"hospitals":          []string{"OIDC1"},
+	})
+	if !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("login as an existing local username = %v, want ErrUsernameTaken", err)
+	}
+}
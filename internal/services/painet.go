@@ -3,17 +3,41 @@ package services
 import (
 	"errors"
 	"fmt"
+	"hospital-api/internal/configs"
 	"hospital-api/internal/models"
+	"hospital-api/internal/validation"
+	"log"
+	"regexp"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+const dateFormat = "2006-01-02"
+
+var (
+	ErrPatientNotFound     = errors.New("patient not found")
+	ErrDuplicateNationalID = errors.New("a patient with this national ID already exists")
+	ErrDuplicateHN         = errors.New("a patient with this HN already exists")
+	ErrDuplicatePassport   = errors.New("a patient with this passport ID already exists")
+	// ErrPatientExists reports a unique violation that raced the duplicate
+	// checks and could not be attributed to a field.
+	ErrPatientExists = errors.New("a patient with these identifiers already exists")
+)
+
 type PatientService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	suggestBudget time.Duration
+	batchMax      int
 }
 
 func NewPatientService(db *gorm.DB) *PatientService {
-	return &PatientService{db: db}
+	return &PatientService{
+		db:            db,
+		suggestBudget: time.Duration(configs.Envs.PatientSuggestTimeoutMillis) * time.Millisecond,
+		batchMax:      int(configs.Envs.PatientBatchMaxIdentifiers),
+	}
 }
 
 func (s *PatientService) SearchPatientByID(hospitalID string, id string) (*models.UserPatient, error) {
@@ -33,19 +57,278 @@ func (s *PatientService) SearchPatientByID(hospitalID string, id string) (*model
 	return &patient, nil
 }
 
-func (s *PatientService) SearchPatients(hospitalID string, params map[string]string) ([]models.UserPatient, error) {
+// SearchPatients returns one page of the patients of hospitalID matching
+// every criterion that is set. Fields and match modes are restricted to
+// patientSearchFields. Fuzzy and free-text name searches are sorted best
+// match first unless page asks for another order. Results are shaped by
+// view, which also limits how fields hidden from the caller may be
+// searched.
+func (s *PatientService) SearchPatients(hospitalID string, criteria *models.PatientSearchCriteria, view *PatientPresenter) (*models.PatientSearchResponse, error) {
+	query, rank, err := applyPatientFilters(s.db.Model(&models.UserPatient{}).Where("hospital_id = ?", hospitalID), searchParams(criteria), criteria.Match, view)
+	if err != nil {
+		return nil, err
+	}
+	if query, err = applyDemographicFilters(query, criteria, view); err != nil {
+		return nil, err
+	}
+	query, queryType := applyFreeText(query, rank, criteria.Q, view)
+	// Count and the page query below must not share statement state.
+	filtered := query.Session(&gorm.Session{})
 
-	query := s.db.Where("hospital_id = ?", hospitalID)
-	for key, value := range params {
-		if value != "" {
-			query = query.Where(fmt.Sprintf("%s = ?", key), value)
-		}
+	query = filtered
+	ranked := len(rank.terms) > 0
+	if ranked {
+		score := "(" + strings.Join(rank.terms, " + ") + ") / ?"
+		query = filtered.Select("user_patients.*, "+score+" AS score", append(rank.args, len(rank.terms))...)
 	}
 
-	var patients []models.UserPatient
-	if err := query.Find(&patients).Error; err != nil {
+	result, err := paginatePatients(query, filtered, &criteria.PatientPageRequest, ranked, view)
+	if err != nil {
+		var fieldErrs validation.Errors
+		if errors.As(err, &fieldErrs) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to query database: %v", err)
 	}
+	result.QueryType = queryType
+	return result, nil
+}
+
+// CreatePatient registers a patient in hospitalID.
+func (s *PatientService) CreatePatient(hospitalID string, staffID uint, req *models.CreatePatientRequest) (*models.UserPatient, error) {
+	patient := &models.UserPatient{
+		NationalID: strings.TrimSpace(req.NationalID),
+		HospitalID: hospitalID,
+	}
+	if err := applyPatientRequest(patient, &req.PatientRequest); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkHNFormat(hospitalID, patient.PatientHN); err != nil {
+		return nil, err
+	}
+	if err := s.checkDuplicates(patient); err != nil {
+		return nil, err
+	}
+	if err := s.db.Omit("Hospital").Create(patient).Error; err != nil {
+		return nil, s.saveError(patient, err)
+	}
+
+	log.Printf("Staff %d registered patient %s in hospital %s", staffID, patient.PatientHN, hospitalID)
+	return patient, nil
+}
+
+// ReplacePatient overwrites every client-settable field of patient id. The
+// national ID identifies the patient and cannot be changed.
+func (s *PatientService) ReplacePatient(hospitalID string, id, staffID uint, req *models.PatientRequest) (*models.UserPatient, error) {
+	patient, err := s.getPatient(hospitalID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPatientRequest(patient, req); err != nil {
+		return nil, err
+	}
+
+	return s.updatePatient(patient, staffID)
+}
+
+// PatchPatient changes the fields present in req.
+func (s *PatientService) PatchPatient(hospitalID string, id, staffID uint, req *models.PatchPatientRequest) (*models.UserPatient, error) {
+	patient, err := s.getPatient(hospitalID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	setString := func(dst *string, src *string) {
+		if src != nil {
+			*dst = strings.TrimSpace(*src)
+		}
+	}
+	setString(&patient.PatientHN, req.PatientHN)
+	setString(&patient.FirstNameTH, req.FirstNameTH)
+	setString(&patient.MiddleNameTH, req.MiddleNameTH)
+	setString(&patient.LastNameTH, req.LastNameTH)
+	setString(&patient.FirstNameEN, req.FirstNameEN)
+	setString(&patient.MiddleNameEN, req.MiddleNameEN)
+	setString(&patient.LastNameEN, req.LastNameEN)
+	setString(&patient.Email, req.Email)
+	if req.PhoneNumber != nil {
+		patient.PhoneNumber = validation.NormalizeThaiMobile(*req.PhoneNumber)
+	}
+	if req.PassportID != nil {
+		patient.PassportID = optionalString(validation.NormalizePassport(*req.PassportID))
+	}
+	if req.Gender != nil {
+		patient.Gender = *req.Gender
+	}
+	if req.DateOfBirth != nil {
+		dob, err := time.Parse(dateFormat, *req.DateOfBirth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date of birth: %v", err)
+		}
+		patient.DateOfBirth = dob
+	}
+
+	return s.updatePatient(patient, staffID)
+}
+
+// DeletePatient removes a patient record of hospitalID.
+func (s *PatientService) DeletePatient(hospitalID string, id, staffID uint) error {
+	result := s.db.Where("id = ? AND hospital_id = ?", id, hospitalID).Delete(&models.UserPatient{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete patient: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPatientNotFound
+	}
+
+	log.Printf("Staff %d deleted a patient record in hospital %s", staffID, hospitalID)
+	return nil
+}
+
+func (s *PatientService) getPatient(hospitalID string, id uint) (*models.UserPatient, error) {
+	var patient models.UserPatient
+	if err := s.db.Where("id = ? AND hospital_id = ?", id, hospitalID).First(&patient).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return &patient, nil
+}
+
+func (s *PatientService) updatePatient(patient *models.UserPatient, staffID uint) (*models.UserPatient, error) {
+	if err := s.checkHNFormat(patient.HospitalID, patient.PatientHN); err != nil {
+		return nil, err
+	}
+	if err := s.checkDuplicates(patient); err != nil {
+		return nil, err
+	}
+	if err := s.db.Omit("Hospital").Save(patient).Error; err != nil {
+		return nil, s.saveError(patient, err)
+	}
+
+	log.Printf("Staff %d updated patient %s in hospital %s", staffID, patient.PatientHN, patient.HospitalID)
+	return patient, nil
+}
+
+// SetHNPattern sets the regular expression the HNs of hospitalID must match
+// on top of the general HN format. An empty pattern removes it.
+func (s *PatientService) SetHNPattern(hospitalID, pattern string) error {
+	if pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return validation.Errors{{Field: "pattern", Message: "is not a valid regular expression: " + err.Error()}}
+		}
+	}
+
+	result := s.db.Model(&models.Hospital{}).Where("id = ?", hospitalID).Update("hn_pattern", pattern)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update hospital: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrHospitalNotFound
+	}
+	return nil
+}
+
+// checkHNFormat applies the hospital's own HN pattern, if it has one.
+func (s *PatientService) checkHNFormat(hospitalID, hn string) error {
+	var hospital models.Hospital
+	if err := s.db.Select("id", "hn_pattern").Where("id = ?", hospitalID).First(&hospital).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrHospitalNotFound
+		}
+		return fmt.Errorf("database error: %v", err)
+	}
+	if hospital.HNPattern == "" {
+		return nil
+	}
+
+	pattern, err := regexp.Compile(hospital.HNPattern)
+	if err != nil {
+		return fmt.Errorf("invalid HN pattern of hospital %s: %v", hospitalID, err)
+	}
+	if !pattern.MatchString(hn) {
+		return validation.Errors{{Field: "patient_hn", Message: "does not match the HN format of hospital " + hospitalID}}
+	}
+	return nil
+}
+
+// checkDuplicates reports which unique identifier of patient is already
+// taken by another record of the same hospital. Other hospitals' records
+// never conflict and are not looked at. A patient that is not saved yet has
+// no ID.
+func (s *PatientService) checkDuplicates(patient *models.UserPatient) error {
+	type uniqueCheck struct {
+		column string
+		value  string
+		err    error
+	}
+	var checks []uniqueCheck
+	if patient.ID == 0 {
+		checks = append(checks, uniqueCheck{"national_id", patient.NationalID, ErrDuplicateNationalID})
+	}
+	checks = append(checks, uniqueCheck{"patient_hn", patient.PatientHN, ErrDuplicateHN})
+	if patient.PassportID != nil {
+		checks = append(checks, uniqueCheck{"passport_id", *patient.PassportID, ErrDuplicatePassport})
+	}
+
+	for _, check := range checks {
+		query := s.db.Model(&models.UserPatient{}).
+			Where("hospital_id = ? AND "+check.column+" = ?", patient.HospitalID, check.value)
+		if patient.ID != 0 {
+			query = query.Where("id <> ?", patient.ID)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+		if count > 0 {
+			return check.err
+		}
+	}
+	return nil
+}
+
+// saveError turns a unique violation from a write that raced
+// checkDuplicates into the matching duplicate error.
+func (s *PatientService) saveError(patient *models.UserPatient, err error) error {
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("failed to save patient: %v", err)
+	}
+	if dupErr := s.checkDuplicates(patient); dupErr != nil {
+		return dupErr
+	}
+	return ErrPatientExists
+}
 
-	return patients, nil
+func applyPatientRequest(patient *models.UserPatient, req *models.PatientRequest) error {
+	dob, err := time.Parse(dateFormat, req.DateOfBirth)
+	if err != nil {
+		return fmt.Errorf("invalid date of birth: %v", err)
+	}
+
+	patient.PatientHN = strings.TrimSpace(req.PatientHN)
+	patient.FirstNameTH = strings.TrimSpace(req.FirstNameTH)
+	patient.MiddleNameTH = strings.TrimSpace(req.MiddleNameTH)
+	patient.LastNameTH = strings.TrimSpace(req.LastNameTH)
+	patient.FirstNameEN = strings.TrimSpace(req.FirstNameEN)
+	patient.MiddleNameEN = strings.TrimSpace(req.MiddleNameEN)
+	patient.LastNameEN = strings.TrimSpace(req.LastNameEN)
+	patient.DateOfBirth = dob
+	patient.PassportID = optionalString(validation.NormalizePassport(req.PassportID))
+	patient.PhoneNumber = validation.NormalizeThaiMobile(req.PhoneNumber)
+	patient.Email = strings.TrimSpace(req.Email)
+	patient.Gender = req.Gender
+	return nil
+}
+
+// optionalString maps a blank value to NULL so that unique columns accept
+// any number of patients without one.
+func optionalString(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
 }
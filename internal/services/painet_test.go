@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"hospital-api/internal/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func stringPtr(s string) *string { return &s }
+
+func TestOptionalString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"AB1234567", stringPtr("AB1234567")},
+		{" AB1234567 ", stringPtr("AB1234567")},
+	}
+	for _, tt := range tests {
+		got := optionalString(tt.in)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("optionalString(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSaveErrorOtherFailure(t *testing.T) {
+	// Errors other than unique violations are not looked into; the nil
+	// database would panic if they were.
+	s := &PatientService{}
+	err := s.saveError(&models.UserPatient{}, errors.New("connection reset"))
+	if err == nil || errors.Is(err, ErrPatientExists) || err.Error() != "failed to save patient: connection reset" {
+		t.Errorf("saveError = %v", err)
+	}
+}
+
+func testPatientRequest(nationalID, hn, passport string) *models.CreatePatientRequest {
+	return &models.CreatePatientRequest{
+		NationalID: nationalID,
+		PatientRequest: models.PatientRequest{
+			PatientHN:   hn,
+			FirstNameTH: "ทดสอบ",
+			LastNameTH:  "ระบบ",
+			DateOfBirth: "1990-01-01",
+			PassportID:  passport,
+			Gender:      models.Male,
+		},
+	}
+}
+
+func TestCreatePatientDuplicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		hospital string
+		req      *models.CreatePatientRequest
+		want     error
+	}{
+		{"unique", "DUP1", testPatientRequest("1100000000002", "HN-2", "AB7654321"), nil},
+		{"national ID", "DUP1", testPatientRequest("1100000000001", "HN-2", ""), ErrDuplicateNationalID},
+		{"HN", "DUP1", testPatientRequest("1100000000002", "HN-1", ""), ErrDuplicateHN},
+		{"HN with spaces", "DUP1", testPatientRequest("1100000000002", " HN-1 ", ""), ErrDuplicateHN},
+		{"passport", "DUP1", testPatientRequest("1100000000002", "HN-2", "AB1234567"), ErrDuplicatePassport},
+		{"passport before normalization", "DUP1", testPatientRequest("1100000000002", "HN-2", "ab 1234567"), ErrDuplicatePassport},
+		{"no passport twice", "DUP1", testPatientRequest("1100000000002", "HN-2", ""), nil},
+		{"other hospital", "DUP2", testPatientRequest("1100000000001", "HN-1", "AB1234567"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			createTestHospitals(t, db, "DUP1", "DUP2")
+			s := NewPatientService(db)
+			if _, err := s.CreatePatient("DUP1", 1, testPatientRequest("1100000000001", "HN-1", "AB1234567")); err != nil {
+				t.Fatalf("CreatePatient: %v", err)
+			}
+			if _, err := s.CreatePatient("DUP1", 1, testPatientRequest("1100000000003", "HN-3", "")); err != nil {
+				t.Fatalf("CreatePatient: %v", err)
+			}
+
+			_, err := s.CreatePatient(tt.hospital, 1, tt.req)
+			if tt.want == nil && err != nil {
+				t.Errorf("CreatePatient = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("CreatePatient = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePatientDuplicates(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "DUP1")
+	s := NewPatientService(db)
+	first, err := s.CreatePatient("DUP1", 1, testPatientRequest("1100000000001", "HN-1", "AB1234567"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreatePatient("DUP1", 1, testPatientRequest("1100000000002", "HN-2", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		patch models.PatchPatientRequest
+		want  error
+	}{
+		{"own HN", models.PatchPatientRequest{PatientHN: stringPtr("HN-2")}, nil},
+		{"HN of another patient", models.PatchPatientRequest{PatientHN: stringPtr("HN-1")}, ErrDuplicateHN},
+		{"passport of another patient", models.PatchPatientRequest{PassportID: stringPtr("ab1234567")}, ErrDuplicatePassport},
+		{"new passport", models.PatchPatientRequest{PassportID: stringPtr("CD1234567")}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.PatchPatient("DUP1", second.ID, 1, &tt.patch)
+			if tt.want == nil && err != nil {
+				t.Errorf("PatchPatient = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("PatchPatient = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Saving a patient unchanged never conflicts with itself.
+	req := testPatientRequest(first.NationalID, first.PatientHN, *first.PassportID)
+	if _, err := s.ReplacePatient("DUP1", first.ID, 1, &req.PatientRequest); err != nil {
+		t.Errorf("ReplacePatient with its own identifiers: %v", err)
+	}
+}
+
+func TestSaveErrorAfterRace(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "DUP1")
+	s := NewPatientService(db)
+	if _, err := s.CreatePatient("DUP1", 1, testPatientRequest("1100000000001", "HN-1", "AB1234567")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The inserts below skip checkDuplicates, as a request that passed it
+	// just before another one committed would.
+	tests := []struct {
+		name    string
+		patient models.UserPatient
+		want    error
+	}{
+		{"national ID", models.UserPatient{NationalID: "1100000000001", PatientHN: "HN-2"}, ErrDuplicateNationalID},
+		{"HN", models.UserPatient{NationalID: "1100000000002", PatientHN: "HN-1"}, ErrDuplicateHN},
+		{"passport", models.UserPatient{NationalID: "1100000000002", PatientHN: "HN-2", PassportID: stringPtr("AB1234567")}, ErrDuplicatePassport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patient := tt.patient
+			patient.HospitalID = "DUP1"
+			err := db.Omit("Hospital").Create(&patient).Error
+			if !errors.Is(err, gorm.ErrDuplicatedKey) {
+				t.Fatalf("Create = %v, want gorm.ErrDuplicatedKey", err)
+			}
+			if got := s.saveError(&patient, err); !errors.Is(got, tt.want) {
+				t.Errorf("saveError = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A violation the checks cannot attribute, e.g. because the conflicting
+	// row was deleted again in the meantime, is still a conflict.
+	unattributed := &models.UserPatient{NationalID: "1100000000009", PatientHN: "HN-9", HospitalID: "DUP1"}
+	if err := s.saveError(unattributed, gorm.ErrDuplicatedKey); !errors.Is(err, ErrPatientExists) {
+		t.Errorf("saveError = %v, want ErrPatientExists", err)
+	}
+}
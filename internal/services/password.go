@@ -0,0 +1,173 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hospital-api/internal/configs"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashAlgorithmArgon2id = "argon2id"
+	HashAlgorithmBcrypt   = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errUnknownHashFormat = errors.New("unknown password hash format")
+
+// PasswordHasher hashes new passwords with the configured algorithm and
+// verifies hashes of every supported algorithm, so that stored hashes can be
+// upgraded transparently after a successful login.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash reports whether hash was produced with a different
+	// algorithm or weaker parameters than the current configuration.
+	NeedsRehash(hash string) bool
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+type passwordHasher struct {
+	algorithm  string
+	argon2     argon2Params
+	bcryptCost int
+}
+
+func NewPasswordHasher(cfg configs.Config) (PasswordHasher, error) {
+	h := &passwordHasher{
+		algorithm: cfg.PasswordHashAlgorithm,
+		argon2: argon2Params{
+			memory:      uint32(cfg.Argon2MemoryKB),
+			iterations:  uint32(cfg.Argon2Iterations),
+			parallelism: uint8(cfg.Argon2Parallelism),
+		},
+		bcryptCost: int(cfg.BcryptCost),
+	}
+
+	switch h.algorithm {
+	case HashAlgorithmArgon2id:
+		if h.argon2.memory < 8*uint32(h.argon2.parallelism) || h.argon2.iterations < 1 || h.argon2.parallelism < 1 {
+			return nil, fmt.Errorf("invalid argon2id parameters: m=%d t=%d p=%d",
+				h.argon2.memory, h.argon2.iterations, h.argon2.parallelism)
+		}
+	case HashAlgorithmBcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", h.bcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", h.algorithm)
+	}
+
+	return h, nil
+}
+
+func (h *passwordHasher) Hash(password string) (string, error) {
+	if h.algorithm == HashAlgorithmBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash password: %v", err)
+		}
+		return string(hashed), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	p := h.argon2
+	key := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.iterations, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *passwordHasher) Verify(hash, password string) (bool, error) {
+	switch {
+	case isArgon2idHash(hash):
+		p, salt, key, err := decodeArgon2idHash(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case isBcryptHash(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, errUnknownHashFormat
+	}
+}
+
+func (h *passwordHasher) NeedsRehash(hash string) bool {
+	switch h.algorithm {
+	case HashAlgorithmArgon2id:
+		if !isArgon2idHash(hash) {
+			return true
+		}
+		p, _, _, err := decodeArgon2idHash(hash)
+		return err != nil || p != h.argon2
+	case HashAlgorithmBcrypt:
+		if !isBcryptHash(hash) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < h.bcryptCost
+	}
+	return false
+}
+
+func isArgon2idHash(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// decodeArgon2idHash parses the PHC string format
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func decodeArgon2idHash(hash string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version: %s", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2 parameters: %v", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2 salt: %v", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2 key: %v", err)
+	}
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"hospital-api/internal/configs"
+	"hospital-api/internal/models"
+	"strings"
+	"testing"
+)
+
+func argon2Config(memoryKB, iterations, parallelism int64) configs.Config {
+	return configs.Config{
+		PasswordHashAlgorithm: HashAlgorithmArgon2id,
+		Argon2MemoryKB:        memoryKB,
+		Argon2Iterations:      iterations,
+		Argon2Parallelism:     parallelism,
+	}
+}
+
+func bcryptConfig(cost int64) configs.Config {
+	return configs.Config{PasswordHashAlgorithm: HashAlgorithmBcrypt, BcryptCost: cost}
+}
+
+func newTestHasher(t *testing.T, cfg configs.Config) PasswordHasher {
+	t.Helper()
+	h, err := NewPasswordHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     configs.Config
+		wantErr bool
+	}{
+		{"argon2id", argon2Config(64*1024, 3, 2), false},
+		{"argon2id too little memory", argon2Config(8, 1, 2), true},
+		{"argon2id no iterations", argon2Config(64, 0, 1), true},
+		{"argon2id no parallelism", argon2Config(64, 1, 0), true},
+		{"bcrypt", bcryptConfig(12), false},
+		{"bcrypt cost too low", bcryptConfig(3), true},
+		{"bcrypt cost too high", bcryptConfig(32), true},
+		{"unknown algorithm", configs.Config{PasswordHashAlgorithm: "md5"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPasswordHasher(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPasswordHasher error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherVerify(t *testing.T) {
+	argon := newTestHasher(t, argon2Config(64, 1, 1))
+	bcrypt := newTestHasher(t, bcryptConfig(4))
+
+	argonHash, err := argon.Hash("s3cret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(argonHash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("argon2id hash = %s", argonHash)
+	}
+	bcryptHash, err := bcrypt.Hash("s3cret-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := argon.Hash("s3cret-password"); again == argonHash {
+		t.Error("two argon2id hashes of the same password are equal; the salt is not random")
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+		wantErr  bool
+	}{
+		{"argon2id", argonHash, "s3cret-password", true, false},
+		{"argon2id wrong password", argonHash, "s3cret-passwore", false, false},
+		{"bcrypt", bcryptHash, "s3cret-password", true, false},
+		{"bcrypt wrong password", bcryptHash, "S3cret-password", false, false},
+		{"empty hash", "", "s3cret-password", false, true},
+		{"plain text", "s3cret-password", "s3cret-password", false, true},
+		{"argon2i", strings.Replace(argonHash, "$argon2id$", "$argon2i$", 1), "s3cret-password", false, true},
+		{"argon2id other version", strings.Replace(argonHash, "v=19", "v=16", 1), "s3cret-password", false, true},
+		{"argon2id missing key", argonHash[:strings.LastIndex(argonHash, "$")], "s3cret-password", false, true},
+	}
+	// Either hasher verifies hashes of every algorithm.
+	hashers := []struct {
+		name   string
+		hasher PasswordHasher
+	}{
+		{"argon2id hasher", argon},
+		{"bcrypt hasher", bcrypt},
+	}
+	for _, h := range hashers {
+		for _, tt := range tests {
+			t.Run(h.name+"/"+tt.name, func(t *testing.T) {
+				got, err := h.hasher.Verify(tt.hash, tt.password)
+				if got != tt.want || (err != nil) != tt.wantErr {
+					t.Errorf("Verify = %v, %v; want %v, error %v", got, err, tt.want, tt.wantErr)
+				}
+			})
+		}
+	}
+
+	if _, err := argon.Verify("", "x"); !errors.Is(err, errUnknownHashFormat) {
+		t.Errorf("Verify of an empty hash = %v, want errUnknownHashFormat", err)
+	}
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	hash := func(cfg configs.Config) string {
+		h, err := newTestHasher(t, cfg).Hash("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	current := argon2Config(64, 2, 1)
+	argonCurrent := hash(current)
+	argonLessMemory := hash(argon2Config(32, 2, 1))
+	argonFewerIterations := hash(argon2Config(64, 1, 1))
+	bcrypt4 := hash(bcryptConfig(4))
+	bcrypt5 := hash(bcryptConfig(5))
+
+	tests := []struct {
+		name   string
+		cfg    configs.Config
+		hash   string
+		rehash bool
+	}{
+		{"argon2id current parameters", current, argonCurrent, false},
+		{"argon2id less memory", current, argonLessMemory, true},
+		{"argon2id fewer iterations", current, argonFewerIterations, true},
+		{"bcrypt to argon2id", current, bcrypt5, true},
+		{"unknown format to argon2id", current, "plain", true},
+		{"bcrypt same cost", bcryptConfig(5), bcrypt5, false},
+		{"bcrypt lower cost", bcryptConfig(5), bcrypt4, true},
+		{"bcrypt higher cost", bcryptConfig(4), bcrypt5, false},
+		{"argon2id to bcrypt", bcryptConfig(5), argonCurrent, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestHasher(t, tt.cfg).NeedsRehash(tt.hash); got != tt.rehash {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.rehash)
+			}
+		})
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "HASH1")
+	argon := newTestHasher(t, argon2Config(64, 1, 1))
+
+	tests := []struct {
+		name     string
+		oldHash  PasswordHasher
+		upgraded bool
+	}{
+		{"bcrypt", newTestHasher(t, bcryptConfig(4)), true},
+		{"weaker argon2id", newTestHasher(t, argon2Config(32, 1, 1)), true},
+		{"current argon2id", argon, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			staff := createTestStaff(t, db, "hash."+strings.ReplaceAll(tt.name, " ", "."), "HASH1", models.RoleClerk)
+			cleanupLoginRecords(t, db, staff.Username)
+			oldHash, err := tt.oldHash.Hash("s3cret-password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			db.Model(staff).Update("password", oldHash)
+
+			g, _ := newTestLoginGuard(db, 5, 0)
+			s := &StaffService{db: db, guard: g, hasher: argon}
+			req := &models.LoginRequest{Username: staff.Username, Password: "s3cret-password"}
+			if _, err := s.LoginByRequest(req, "10.0.0.1"); err != nil {
+				t.Fatalf("LoginByRequest: %v", err)
+			}
+
+			var stored models.UserStaff
+			if err := db.First(&stored, staff.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if upgraded := stored.Password != oldHash; upgraded != tt.upgraded {
+				t.Fatalf("hash upgraded = %v, want %v", upgraded, tt.upgraded)
+			}
+			if argon.NeedsRehash(stored.Password) {
+				t.Errorf("stored hash %s does not use the current parameters", stored.Password)
+			}
+
+			// The upgraded hash keeps working.
+			if _, err := s.LoginByRequest(req, "10.0.0.1"); err != nil {
+				t.Errorf("login after the upgrade: %v", err)
+			}
+		})
+	}
+}
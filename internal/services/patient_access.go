@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"hospital-api/internal/models"
+	"log"
+	"strings"
+)
+
+// UnmaskPatient reveals fields of a patient of hospitalID in full to
+// staffID, recording who asked, why and from where. Fields defaults to
+// every personal data field; view applies the fields= projection.
+func (s *PatientService) UnmaskPatient(hospitalID string, id, staffID uint, role models.Role, client ClientInfo, req *models.UnmaskPatientRequest, projection string) (models.PatientView, error) {
+	fields := req.Fields
+	if len(fields) == 0 {
+		fields = piiFields
+	}
+
+	// Start from the caller's own policy so that only the requested fields
+	// are revealed.
+	access := make(map[string]piiAccess, len(piiFields))
+	for field, level := range piiPolicies[role] {
+		access[field] = level
+	}
+	for _, field := range fields {
+		access[field] = piiFull
+	}
+	view, err := newPatientPresenter(access, projection)
+	if err != nil {
+		return nil, err
+	}
+
+	patient, err := s.getPatient(hospitalID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &models.PatientAccessLog{
+		HospitalID: hospitalID,
+		PatientID:  patient.ID,
+		StaffID:    staffID,
+		Role:       role,
+		Fields:     strings.Join(fields, ","),
+		Reason:     strings.TrimSpace(req.Reason),
+		IPAddress:  client.IPAddress,
+		UserAgent:  truncateUserAgent(client.UserAgent),
+	}
+	// The data is only returned once the access is on record.
+	if err := s.db.Create(entry).Error; err != nil {
+		return nil, fmt.Errorf("failed to record patient access: %v", err)
+	}
+
+	log.Printf("SECURITY: staff %d (%s) unmasked %s of patient %d in hospital %s: %s",
+		staffID, role, entry.Fields, patient.ID, hospitalID, entry.Reason)
+	return view.Patient(patient), nil
+}
+
+// ListPatientAccessLogs returns the unmask history of a patient of
+// hospitalID, newest first.
+func (s *PatientService) ListPatientAccessLogs(hospitalID string, id uint) ([]models.PatientAccessLog, error) {
+	var logs []models.PatientAccessLog
+	err := s.db.Where("hospital_id = ? AND patient_id = ?", hospitalID, id).
+		Order("created_at DESC").
+		Find(&logs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query database: %v", err)
+	}
+	return logs, nil
+}
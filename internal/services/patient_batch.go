@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"hospital-api/internal/models"
+	"hospital-api/internal/validation"
+	"strings"
+)
+
+// batchFields are the identifier fields of a batch lookup, in the order
+// they win when one identifier matches several patients.
+var batchFields = []string{"national_id", "patient_hn", "passport_id"}
+
+// LookupPatients finds the patients of hospitalID for every identifier in
+// one query. idType limits the match to one identifier field. Patients are
+// shaped by view.
+func (s *PatientService) LookupPatients(hospitalID string, identifiers []string, idType string, view *PatientPresenter) (*models.PatientBatchResponse, error) {
+	if len(identifiers) > s.batchMax {
+		return nil, fieldError("identifiers", fmt.Sprintf("must contain at most %d items", s.batchMax))
+	}
+
+	fields := batchFields
+	if idType != "" {
+		fields = []string{idType}
+	}
+
+	// Passports are stored normalized, so they are looked up that way and
+	// mapped back to the identifier as sent.
+	var ids, passports []string
+	passportOf := make(map[string]string, len(identifiers))
+	for _, id := range identifiers {
+		id = strings.TrimSpace(id)
+		if _, seen := passportOf[id]; seen {
+			continue
+		}
+		ids = append(ids, id)
+		passport := validation.NormalizePassport(id)
+		passportOf[id] = passport
+		passports = append(passports, passport)
+	}
+
+	query := s.db.Where("hospital_id = ?", hospitalID)
+	var conds []string
+	var args []interface{}
+	for _, field := range fields {
+		conds = append(conds, field+" IN ?")
+		if field == "passport_id" {
+			args = append(args, passports)
+		} else {
+			args = append(args, ids)
+		}
+	}
+	query = query.Where("("+strings.Join(conds, " OR ")+")", args...)
+
+	var patients []models.UserPatient
+	if err := query.Find(&patients).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %v", err)
+	}
+
+	index := map[string]map[string]*models.UserPatient{
+		"national_id": {},
+		"patient_hn":  {},
+		"passport_id": {},
+	}
+	for i := range patients {
+		p := &patients[i]
+		index["national_id"][p.NationalID] = p
+		index["patient_hn"][p.PatientHN] = p
+		if p.PassportID != nil {
+			index["passport_id"][*p.PassportID] = p
+		}
+	}
+
+	response := &models.PatientBatchResponse{Results: make(map[string]models.PatientBatchResult, len(identifiers))}
+	for _, raw := range identifiers {
+		if _, seen := response.Results[raw]; seen {
+			continue
+		}
+		id := strings.TrimSpace(raw)
+		result := models.PatientBatchResult{}
+		for _, field := range fields {
+			key := id
+			if field == "passport_id" {
+				key = passportOf[id]
+			}
+			if p, ok := index[field][key]; ok {
+				result = models.PatientBatchResult{Found: true, MatchedBy: field, Patient: view.Patient(p)}
+				break
+			}
+		}
+		if result.Found {
+			response.Found++
+		} else {
+			response.NotFound++
+		}
+		response.Results[raw] = result
+	}
+	return response, nil
+}
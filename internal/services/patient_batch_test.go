@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"hospital-api/internal/models"
+	"hospital-api/internal/validation"
+	"testing"
+)
+
+func TestLookupPatientsLimit(t *testing.T) {
+	// The limit applies before anything reaches the database, which is nil
+	// here, and counts repeated identifiers too.
+	s := &PatientService{batchMax: 2}
+	_, err := s.LookupPatients("H001", []string{"HN-1", "HN-1", "HN-2"}, "", nil)
+	var fieldErrs validation.Errors
+	if !errors.As(err, &fieldErrs) || len(fieldErrs) != 1 || fieldErrs[0].Field != "identifiers" {
+		t.Errorf("LookupPatients = %v, want a field error on identifiers", err)
+	}
+}
+
+func TestLookupPatients(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "BAT1", "BAT2")
+	s := NewPatientService(db)
+	s.batchMax = 10
+	view, err := NewPatientPresenter(models.RoleAdmin, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(hospitalID string, req *models.CreatePatientRequest) *models.UserPatient {
+		p, err := s.CreatePatient(hospitalID, 1, req)
+		if err != nil {
+			t.Fatalf("CreatePatient: %v", err)
+		}
+		return p
+	}
+	first := create("BAT1", testPatientRequest("1100000000001", "HN-1", "AB1234567"))
+	// The HN of second is the national ID of first.
+	second := create("BAT1", testPatientRequest("1100000000002", "1100000000001", ""))
+	create("BAT2", testPatientRequest("1100000000003", "HN-3", ""))
+
+	type match struct {
+		matchedBy string
+		patient   uint
+	}
+	tests := []struct {
+		name        string
+		identifiers []string
+		idType      string
+		want        map[string]match // absent from the map means not found
+		found       int
+		notFound    int
+	}{
+		{
+			name:        "each field",
+			identifiers: []string{"1100000000002", "HN-1", "AB1234567"},
+			want: map[string]match{
+				"1100000000002": {"national_id", second.ID},
+				"HN-1":          {"patient_hn", first.ID},
+				"AB1234567":     {"passport_id", first.ID},
+			},
+			found: 3,
+		},
+		{
+			name:        "passport as typed",
+			identifiers: []string{"ab 1234567", " AB1234567 "},
+			want: map[string]match{
+				"ab 1234567":  {"passport_id", first.ID},
+				" AB1234567 ": {"passport_id", first.ID},
+			},
+			found: 2,
+		},
+		{
+			name:        "repeated identifier",
+			identifiers: []string{"HN-1", "HN-1", "unknown", "unknown"},
+			want:        map[string]match{"HN-1": {"patient_hn", first.ID}},
+			found:       1,
+			notFound:    1,
+		},
+		{
+			name:        "national ID wins",
+			identifiers: []string{"1100000000001"},
+			want:        map[string]match{"1100000000001": {"national_id", first.ID}},
+			found:       1,
+		},
+		{
+			name:        "restricted to HN",
+			identifiers: []string{"1100000000001", "AB1234567"},
+			idType:      "patient_hn",
+			want:        map[string]match{"1100000000001": {"patient_hn", second.ID}},
+			found:       1,
+			notFound:    1,
+		},
+		{
+			name:        "other hospital",
+			identifiers: []string{"1100000000003", "HN-3"},
+			notFound:    2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.LookupPatients("BAT1", tt.identifiers, tt.idType, view)
+			if err != nil {
+				t.Fatalf("LookupPatients: %v", err)
+			}
+			if got.Found != tt.found || got.NotFound != tt.notFound {
+				t.Errorf("found %d, not found %d; want %d, %d", got.Found, got.NotFound, tt.found, tt.notFound)
+			}
+			if len(got.Results) != tt.found+tt.notFound {
+				t.Errorf("%d results, want one per distinct identifier", len(got.Results))
+			}
+			for _, id := range tt.identifiers {
+				result, ok := got.Results[id]
+				if !ok {
+					t.Errorf("no result for %q", id)
+					continue
+				}
+				want, found := tt.want[id]
+				if result.Found != found {
+					t.Errorf("%q: found %v, want %v", id, result.Found, found)
+					continue
+				}
+				if found && (result.MatchedBy != want.matchedBy || result.Patient["id"] != want.patient) {
+					t.Errorf("%q: patient %v by %s, want %d by %s", id, result.Patient["id"], result.MatchedBy, want.patient, want.matchedBy)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"hospital-api/internal/models"
+	"hospital-api/internal/validation"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// today returns the current date. Dates of birth are stored as UTC
+// midnight, so age bands are computed on UTC dates.
+var today = func() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// applyDemographicFilters adds the date of birth, age, gender and
+// registration date filters of criteria. Every range includes both ends.
+// Callers that may not see dates of birth can only match an exact date.
+func applyDemographicFilters(query *gorm.DB, criteria *models.PatientSearchCriteria, view *PatientPresenter) (*gorm.DB, error) {
+	var fieldErrs validation.Errors
+	parse := func(field, value string, loc *time.Location) time.Time {
+		if value == "" {
+			return time.Time{}
+		}
+		date, err := time.ParseInLocation(dateFormat, value, loc)
+		if err != nil {
+			fieldErrs = append(fieldErrs, models.FieldError{Field: field, Message: "must be a date in YYYY-MM-DD format"})
+		}
+		return date
+	}
+
+	dob := parse("date_of_birth", criteria.DateOfBirth, time.UTC)
+	dobFrom := parse("dob_from", criteria.DOBFrom, time.UTC)
+	dobTo := parse("dob_to", criteria.DOBTo, time.UTC)
+	// Registration dates follow the server's calendar.
+	createdFrom := parse("created_from", criteria.CreatedFrom, time.Local)
+	createdTo := parse("created_to", criteria.CreatedTo, time.Local)
+
+	if !dobFrom.IsZero() && !dobTo.IsZero() && dobTo.Before(dobFrom) {
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "dob_to", Message: "must not be before dob_from"})
+	}
+	if !createdFrom.IsZero() && !createdTo.IsZero() && createdTo.Before(createdFrom) {
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "created_to", Message: "must not be before created_from"})
+	}
+	if criteria.AgeMin != nil && criteria.AgeMax != nil && *criteria.AgeMax < *criteria.AgeMin {
+		fieldErrs = append(fieldErrs, models.FieldError{Field: "age_max", Message: "must not be less than age_min"})
+	}
+	if !view.sees("date_of_birth") {
+		ranges := []struct {
+			field string
+			set   bool
+		}{
+			{"dob_from", criteria.DOBFrom != ""},
+			{"dob_to", criteria.DOBTo != ""},
+			{"age_min", criteria.AgeMin != nil},
+			{"age_max", criteria.AgeMax != nil},
+		}
+		for _, r := range ranges {
+			if r.set {
+				fieldErrs = append(fieldErrs, models.FieldError{Field: r.field, Message: "is not available without access to dates of birth"})
+			}
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return nil, fieldErrs
+	}
+
+	// Half-open ranges keep the date_of_birth and created_at indexes usable.
+	if !dob.IsZero() {
+		query = query.Where("date_of_birth >= ? AND date_of_birth < ?", dob, dob.AddDate(0, 0, 1))
+	}
+	if !dobFrom.IsZero() {
+		query = query.Where("date_of_birth >= ?", dobFrom)
+	}
+	if !dobTo.IsZero() {
+		query = query.Where("date_of_birth < ?", dobTo.AddDate(0, 0, 1))
+	}
+
+	bornFrom, bornBefore := ageBirthRange(today(), criteria.AgeMin, criteria.AgeMax)
+	if !bornFrom.IsZero() {
+		query = query.Where("date_of_birth >= ?", bornFrom)
+	}
+	if !bornBefore.IsZero() {
+		query = query.Where("date_of_birth < ?", bornBefore)
+	}
+
+	if len(criteria.Gender) > 0 {
+		query = query.Where("gender IN ?", criteria.Gender)
+	}
+
+	if !createdFrom.IsZero() {
+		query = query.Where("created_at >= ?", createdFrom)
+	}
+	if !createdTo.IsZero() {
+		query = query.Where("created_at < ?", createdTo.AddDate(0, 0, 1))
+	}
+	return query, nil
+}
+
+// ageBirthRange returns the half-open range of dates of birth of patients
+// aged minAge to maxAge years on now; a nil bound leaves its end zero. A
+// patient is at least n years old when born on or before now's date n years
+// ago, and at most n when born after it n+1 years ago.
+func ageBirthRange(now time.Time, minAge, maxAge *int) (from, before time.Time) {
+	if maxAge != nil {
+		from = now.AddDate(-*maxAge-1, 0, 1)
+	}
+	if minAge != nil {
+		before = now.AddDate(-*minAge, 0, 1)
+	}
+	return from, before
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"hospital-api/internal/models"
+	"hospital-api/internal/validation"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB builds SQL without a database connection.
+func dryRunDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("open dry-run database: %v", err)
+	}
+	return db
+}
+
+func date(s string) time.Time {
+	d, err := time.Parse(dateFormat, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func intPtr(n int) *int { return &n }
+
+func TestAgeBirthRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      string
+		min, max *int
+		born     string
+		want     bool
+	}{
+		{"turns min age today", "2026-03-15", intPtr(18), nil, "2008-03-15", true},
+		{"turns min age tomorrow", "2026-03-15", intPtr(18), nil, "2008-03-16", false},
+		{"well above min age", "2026-03-15", intPtr(18), nil, "1950-01-01", true},
+		{"last day at max age", "2026-03-15", nil, intPtr(18), "2007-03-16", true},
+		{"one year past max age", "2026-03-15", nil, intPtr(18), "2007-03-15", false},
+		{"newborn within max age", "2026-03-15", nil, intPtr(18), "2026-03-15", true},
+		{"exact age band low end", "2026-03-15", intPtr(30), intPtr(30), "1996-03-15", true},
+		{"exact age band high end", "2026-03-15", intPtr(30), intPtr(30), "1995-03-16", true},
+		{"below exact age band", "2026-03-15", intPtr(30), intPtr(30), "1996-03-16", false},
+		{"above exact age band", "2026-03-15", intPtr(30), intPtr(30), "1995-03-15", false},
+		{"leap day birthday not reached on 28 Feb", "2026-02-28", intPtr(18), nil, "2008-02-29", false},
+		{"leap day birthday reached on 1 Mar", "2026-03-01", intPtr(18), nil, "2008-02-29", true},
+		{"age zero", "2026-03-15", intPtr(0), intPtr(0), "2025-03-16", true},
+		{"age zero excludes one year olds", "2026-03-15", intPtr(0), intPtr(0), "2025-03-15", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, before := ageBirthRange(date(tt.now), tt.min, tt.max)
+			born := date(tt.born)
+			got := (from.IsZero() || !born.Before(from)) && (before.IsZero() || born.Before(before))
+			if got != tt.want {
+				t.Errorf("born %s, on %s, ages %v-%v: in range = %v, want %v (range [%v, %v))",
+					tt.born, tt.now, deref(tt.min), deref(tt.max), got, tt.want, from, before)
+			}
+		})
+	}
+}
+
+func deref(n *int) interface{} {
+	if n == nil {
+		return nil
+	}
+	return *n
+}
+
+func TestApplyDemographicFilters(t *testing.T) {
+	defer func(original func() time.Time) { today = original }(today)
+	today = func() time.Time { return date("2026-03-15") }
+
+	staff, _ := NewPatientPresenter(models.RoleDoctor, false, "")
+	auditor, _ := NewPatientPresenter(models.RoleAuditor, false, "")
+
+	tests := []struct {
+		name      string
+		criteria  models.PatientSearchCriteria
+		view      *PatientPresenter
+		wantWhere string
+		wantVars  []interface{}
+		wantErrs  []string
+	}{
+		{
+			name:      "exact date of birth is a one day range",
+			criteria:  models.PatientSearchCriteria{DateOfBirth: "1990-01-15"},
+			view:      staff,
+			wantWhere: "date_of_birth >= $1 AND date_of_birth < $2",
+			wantVars:  []interface{}{date("1990-01-15"), date("1990-01-16")},
+		},
+		{
+			name:      "date of birth range includes both ends",
+			criteria:  models.PatientSearchCriteria{DOBFrom: "1990-01-01", DOBTo: "1990-12-31"},
+			view:      staff,
+			wantWhere: "date_of_birth >= $1 AND date_of_birth < $2",
+			wantVars:  []interface{}{date("1990-01-01"), date("1991-01-01")},
+		},
+		{
+			name:      "age band",
+			criteria:  models.PatientSearchCriteria{AgeMin: intPtr(18), AgeMax: intPtr(65)},
+			view:      staff,
+			wantWhere: "date_of_birth >= $1 AND date_of_birth < $2",
+			wantVars:  []interface{}{date("1960-03-16"), date("2008-03-16")},
+		},
+		{
+			name:     "reversed ranges",
+			criteria: models.PatientSearchCriteria{DOBFrom: "1991-01-01", DOBTo: "1990-01-01", AgeMin: intPtr(40), AgeMax: intPtr(30)},
+			view:     staff,
+			wantErrs: []string{"dob_to", "age_max"},
+		},
+		{
+			name:     "malformed date",
+			criteria: models.PatientSearchCriteria{DateOfBirth: "15/01/1990"},
+			view:     staff,
+			wantErrs: []string{"date_of_birth"},
+		},
+		{
+			name:      "exact date of birth without access to dates of birth",
+			criteria:  models.PatientSearchCriteria{DateOfBirth: "1990-01-15"},
+			view:      auditor,
+			wantWhere: "date_of_birth >= $1 AND date_of_birth < $2",
+			wantVars:  []interface{}{date("1990-01-15"), date("1990-01-16")},
+		},
+		{
+			name:     "ranges without access to dates of birth",
+			criteria: models.PatientSearchCriteria{DOBFrom: "1990-01-01", AgeMin: intPtr(18)},
+			view:     auditor,
+			wantErrs: []string{"dob_from", "age_min"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := applyDemographicFilters(dryRunDB(t).Model(&models.UserPatient{}), &tt.criteria, tt.view)
+			if tt.wantErrs != nil {
+				var fieldErrs validation.Errors
+				errors.As(err, &fieldErrs)
+				var fields []string
+				for _, fe := range fieldErrs {
+					fields = append(fields, fe.Field)
+				}
+				if !reflect.DeepEqual(fields, tt.wantErrs) {
+					t.Fatalf("errors on %v, want %v (err: %v)", fields, tt.wantErrs, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			stmt := query.Find(&[]models.UserPatient{}).Statement
+			want := `SELECT * FROM "user_patients" WHERE ` + tt.wantWhere
+			if got := stmt.SQL.String(); got != want {
+				t.Errorf("SQL = %s\nwant  %s", got, want)
+			}
+			if !reflect.DeepEqual(stmt.Vars, tt.wantVars) {
+				t.Errorf("vars = %v, want %v", stmt.Vars, tt.wantVars)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"hospital-api/internal/models"
+	"hospital-api/internal/validation"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPatientPageSize = 20
+	maxPatientPageSize     = 100
+
+	sortByScore   = "score"
+	sortAscending = "asc"
+)
+
+// patientSortField declares a column patient search results may be sorted
+// by. value reads the column from a result to build the next cursor.
+type patientSortField struct {
+	name   string
+	column string
+	value  func(p *models.PatientMatch) string
+}
+
+var patientSortFields = []patientSortField{
+	{name: "patient_hn", column: "patient_hn", value: func(p *models.PatientMatch) string { return p.PatientHN }},
+	{name: "first_name_th", column: "first_name_th", value: func(p *models.PatientMatch) string { return p.FirstNameTH }},
+	{name: "last_name_th", column: "last_name_th", value: func(p *models.PatientMatch) string { return p.LastNameTH }},
+	{name: "first_name_en", column: "first_name_en", value: func(p *models.PatientMatch) string { return p.FirstNameEN }},
+	{name: "last_name_en", column: "last_name_en", value: func(p *models.PatientMatch) string { return p.LastNameEN }},
+	{name: "date_of_birth", column: "date_of_birth", value: func(p *models.PatientMatch) string { return p.DateOfBirth.Format(time.RFC3339Nano) }},
+	{name: "created_at", column: "created_at", value: func(p *models.PatientMatch) string { return p.CreatedAt.Format(time.RFC3339Nano) }},
+}
+
+// patientCursor is the decoded form of an opaque page cursor. Results
+// sorted by a column continue after the (value, HN) of the last row; the HN
+// is unique within the hospital and, unlike the national ID, is shown to
+// every caller, so the cursor reveals nothing the page did not. Relevance
+// scores have no stable key, so their cursor holds an offset.
+type patientCursor struct {
+	Sort   string `json:"s"`
+	Order  string `json:"o"`
+	Value  string `json:"v,omitempty"`
+	HN     string `json:"hn,omitempty"`
+	Offset int    `json:"n,omitempty"`
+}
+
+func (c *patientCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePatientCursor(s string) (*patientCursor, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	var cursor patientCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, false
+	}
+	return &cursor, true
+}
+
+// paginatePatients sorts query, restricts it to the requested page and
+// fills in the pagination metadata. ranked tells whether query selects a
+// relevance score; filtered is the same query without the selection, for
+// counting. The page is shaped by view.
+func paginatePatients(query, filtered *gorm.DB, page *models.PatientPageRequest, ranked bool, view *PatientPresenter) (*models.PatientSearchResponse, error) {
+	pagination := models.Pagination{
+		Limit:  page.Limit,
+		Offset: page.Offset,
+		Sort:   page.Sort,
+		Order:  strings.ToLower(page.Order),
+	}
+	if pagination.Limit < 1 {
+		pagination.Limit = defaultPatientPageSize
+	}
+	if pagination.Limit > maxPatientPageSize {
+		pagination.Limit = maxPatientPageSize
+	}
+	if pagination.Sort == "" {
+		pagination.Sort = "patient_hn"
+		if ranked {
+			pagination.Sort = sortByScore
+		}
+	}
+	if pagination.Order == "" {
+		pagination.Order = sortAscending
+		if pagination.Sort == sortByScore {
+			pagination.Order = "desc"
+		}
+	}
+
+	var sortField patientSortField
+	if pagination.Sort == sortByScore {
+		if !ranked {
+			return nil, fieldError("sort", "score is only available with fuzzy matching")
+		}
+	} else {
+		var ok bool
+		if sortField, ok = findPatientSortField(pagination.Sort); !ok {
+			return nil, fieldError("sort", "must be one of: "+patientSortNames(ranked))
+		}
+		// The order of results would leak the values the caller cannot see.
+		if !view.sees(sortField.name) {
+			return nil, fieldError("sort", "is not available for a field you cannot see")
+		}
+	}
+
+	var cursor *patientCursor
+	if page.Cursor != "" {
+		if page.Offset > 0 {
+			return nil, fieldError("cursor", "cannot be combined with offset")
+		}
+		var ok bool
+		cursor, ok = decodePatientCursor(page.Cursor)
+		if !ok || cursor.Sort != pagination.Sort || cursor.Order != pagination.Order {
+			return nil, fieldError("cursor", "is invalid or was issued for a different sort order")
+		}
+	}
+
+	if page.IncludeTotal {
+		var total int64
+		if err := filtered.Count(&total).Error; err != nil {
+			return nil, err
+		}
+		pagination.Total = &total
+	}
+
+	direction := " ASC"
+	comparison := ">"
+	if pagination.Order == "desc" {
+		direction = " DESC"
+		comparison = "<"
+	}
+	if pagination.Sort == sortByScore {
+		query = query.Order("score" + direction).Order("patient_hn ASC")
+		if cursor != nil {
+			pagination.Offset = cursor.Offset
+		}
+	} else {
+		query = query.Order(sortField.column + direction).Order("patient_hn" + direction)
+		if cursor != nil {
+			query = query.Where("("+sortField.column+", patient_hn) "+comparison+" (?, ?)", cursor.Value, cursor.HN)
+		}
+	}
+
+	var patients []models.PatientMatch
+	err := query.Offset(pagination.Offset).Limit(pagination.Limit + 1).Scan(&patients).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patients) > pagination.Limit {
+		patients = patients[:pagination.Limit]
+		pagination.HasMore = true
+
+		next := &patientCursor{Sort: pagination.Sort, Order: pagination.Order}
+		if pagination.Sort == sortByScore {
+			next.Offset = pagination.Offset + pagination.Limit
+		} else {
+			last := &patients[len(patients)-1]
+			next.Value = sortField.value(last)
+			next.HN = last.PatientHN
+		}
+		pagination.NextCursor = next.encode()
+	}
+
+	views := make([]models.PatientView, len(patients))
+	for i := range patients {
+		views[i] = view.Match(&patients[i])
+	}
+
+	return &models.PatientSearchResponse{
+		Patients:   views,
+		Count:      len(views),
+		Pagination: pagination,
+	}, nil
+}
+
+func findPatientSortField(name string) (patientSortField, bool) {
+	for _, field := range patientSortFields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return patientSortField{}, false
+}
+
+func patientSortNames(ranked bool) string {
+	names := make([]string, 0, len(patientSortFields)+1)
+	for _, field := range patientSortFields {
+		names = append(names, field.name)
+	}
+	if ranked {
+		names = append(names, sortByScore)
+	}
+	return strings.Join(names, " ")
+}
+
+func fieldError(field, message string) error {
+	return validation.Errors{{Field: field, Message: message}}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestPatientCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor patientCursor
+	}{
+		{"column sort", patientCursor{Sort: "last_name_th", Order: "asc", Value: "ใจดี", HN: "HN0001"}},
+		{"descending dates", patientCursor{Sort: "date_of_birth", Order: "desc", Value: "1990-01-15T00:00:00Z", HN: "HN0042"}},
+		{"empty sort value", patientCursor{Sort: "first_name_en", Order: "asc", Value: "", HN: "HN0003"}},
+		{"relevance offset", patientCursor{Sort: "score", Order: "desc", Offset: 40}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := tt.cursor.encode()
+			if strings.ContainsAny(encoded, "+/=") {
+				t.Errorf("cursor %q is not URL safe", encoded)
+			}
+			decoded, ok := decodePatientCursor(encoded)
+			if !ok {
+				t.Fatalf("decodePatientCursor(%q) failed", encoded)
+			}
+			if *decoded != tt.cursor {
+				t.Errorf("round trip = %+v, want %+v", *decoded, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodePatientCursorInvalid(t *testing.T) {
+	for _, s := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		base64.StdEncoding.EncodeToString([]byte(`{"s":"patient_hn","o":"asc"}`)),
+	} {
+		if _, ok := decodePatientCursor(s); ok {
+			t.Errorf("decodePatientCursor(%q) accepted an invalid cursor", s)
+		}
+	}
+}
+
+func TestPatientCursorOmitsNationalID(t *testing.T) {
+	cursor := patientCursor{Sort: "patient_hn", Order: "asc", Value: "HN0001", HN: "HN0001"}
+	data, err := base64.RawURLEncoding.DecodeString(cursor.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "national") || strings.Contains(string(data), `"id"`) {
+		t.Errorf("cursor carries a national ID field: %s", data)
+	}
+}
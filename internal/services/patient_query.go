@@ -0,0 +1,158 @@
+package services
+
+import (
+	"hospital-api/internal/validation"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of free-text query reported in PatientSearchResponse.QueryType.
+const (
+	queryNationalID   = "national_id"
+	queryPhoneNumber  = "phone_number"
+	queryEmail        = "email"
+	queryPatientHN    = "patient_hn"
+	queryPassportID   = "passport_id"
+	queryHNOrPassport = "hn_or_passport"
+	queryName         = "name"
+)
+
+// prefixMatchScore is the relevance of a name that starts with the search
+// term; an exact (case-insensitive) match scores 1.
+const prefixMatchScore = 0.8
+
+// applyFreeText adds the conditions for the single search box value q and
+// returns what q was taken to be. Identifiers match exactly; anything else
+// is searched as names and contributes to rank. Callers that may not see
+// names only find whole names.
+func applyFreeText(query *gorm.DB, rank *relevance, q string, view *PatientPresenter) (*gorm.DB, string) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return query, ""
+	}
+	exact := !view.sees("first_name_th")
+
+	if !strings.ContainsAny(q, "0123456789@") {
+		return matchNames(query, rank, strings.Fields(q), exact), queryName
+	}
+	if strings.Contains(q, "@") {
+		return query.Where("email ILIKE ?", escapeLike(q)), queryEmail
+	}
+	if digits := strings.NewReplacer("-", "", " ", "").Replace(q); len(digits) == 13 && isDigits(digits) {
+		return query.Where("national_id = ?", digits), queryNationalID
+	}
+	if phone := validation.NormalizeThaiMobile(q); validation.IsThaiMobile(phone) {
+		return query.Where("phone_number = ?", phone), queryPhoneNumber
+	}
+
+	// HNs and passport numbers overlap (e.g. "HN00123"), so a value valid
+	// as both is looked up as both.
+	passport := validation.NormalizePassport(q)
+	isPassport, isHN := validation.IsPassportNumber(passport), validation.IsHN(q)
+	switch {
+	case isPassport && isHN:
+		return query.Where("(patient_hn = ? OR passport_id = ?)", q, passport), queryHNOrPassport
+	case isHN:
+		return query.Where("patient_hn = ?", q), queryPatientHN
+	case isPassport:
+		return query.Where("passport_id = ?", passport), queryPassportID
+	}
+	return matchNames(query, rank, strings.Fields(q), exact), queryName
+}
+
+// matchNames searches the names in the script of each term. A single term
+// may be any name; with more terms the first is the first name, the last
+// is the last name and any others are middle names. exact only accepts
+// whole names, case-insensitively, and does not rank.
+func matchNames(query *gorm.DB, rank *relevance, terms []string, exact bool) *gorm.DB {
+	for i, term := range terms {
+		var names []string
+		switch {
+		case len(terms) == 1:
+			names = []string{"first_name", "middle_name", "last_name"}
+		case i == 0:
+			names = []string{"first_name"}
+		case i == len(terms)-1:
+			names = []string{"last_name"}
+		default:
+			names = []string{"middle_name"}
+		}
+
+		suffix := "_en"
+		if isThai(term) {
+			suffix = "_th"
+		}
+
+		var conds, scores []string
+		var condArgs, scoreArgs []interface{}
+		for _, name := range names {
+			field, _ := findPatientSearchField(name + suffix)
+			if exact {
+				conds, condArgs = append(conds, field.column+" ILIKE ?"), append(condArgs, escapeLike(term))
+				continue
+			}
+			cond, args, score, sArgs := nameTermMatch(field, term)
+			conds, condArgs = append(conds, cond), append(condArgs, args...)
+			scores, scoreArgs = append(scores, score), append(scoreArgs, sArgs...)
+		}
+
+		query = query.Where("("+strings.Join(conds, " OR ")+")", condArgs...)
+		if exact {
+			continue
+		}
+		rank.terms = append(rank.terms, greatest(scores))
+		rank.args = append(rank.args, scoreArgs...)
+	}
+	return query
+}
+
+// nameTermMatch returns the condition and relevance of term against one
+// name field: a substring, trigram or phonetic match, scored highest for
+// an exact name and then for a prefix.
+func nameTermMatch(field patientSearchField, term string) (string, []interface{}, string, []interface{}) {
+	col := field.column
+	cond := col + " ILIKE ? OR " + col + " % ?"
+	args := []interface{}{"%" + escapeLike(term) + "%", term}
+	scores := []string{
+		"similarity(" + col + ", ?)",
+		"CASE WHEN " + col + " ILIKE ? THEN 1.0::float8 WHEN " + col + " ILIKE ? THEN ?::float8 ELSE 0 END",
+	}
+	scoreArgs := []interface{}{term, escapeLike(term), escapeLike(term) + "%", prefixMatchScore}
+
+	if field.key != nil {
+		if key := field.key(term); key != "" {
+			cond += " OR " + field.keyColumn + " = ?"
+			args = append(args, key)
+			scores = append(scores, "CASE WHEN "+field.keyColumn+" = ? THEN ?::float8 ELSE 0 END")
+			scoreArgs = append(scoreArgs, key, phoneticMatchScore)
+		}
+	}
+	return cond, args, greatest(scores), scoreArgs
+}
+
+func greatest(exprs []string) string {
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	return "GREATEST(" + strings.Join(exprs, ", ") + ")"
+}
+
+func isThai(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Thai, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"hospital-api/internal/models"
+	"hospital-api/internal/phonetic"
+	"hospital-api/internal/validation"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// patientSearchField declares a searchable patient column and the match
+// modes it supports. Only declared fields ever reach SQL.
+type patientSearchField struct {
+	name   string
+	column string
+	// modes lists the allowed match modes; the first is the default.
+	modes []models.MatchMode
+	// keyColumn holds the phonetic key computed by key, for fuzzy matches.
+	keyColumn string
+	key       func(string) string
+}
+
+// phoneticMatchScore is the relevance of a name that sounds like the
+// search term but is spelled differently.
+const phoneticMatchScore = 0.9
+
+var (
+	identifierModes = []models.MatchMode{models.MatchExact}
+	wholeValueModes = []models.MatchMode{models.MatchIExact, models.MatchExact}
+	nameModes       = []models.MatchMode{models.MatchContains, models.MatchPrefix, models.MatchIExact, models.MatchExact}
+	fuzzyNameModes  = []models.MatchMode{models.MatchContains, models.MatchPrefix, models.MatchIExact, models.MatchExact, models.MatchFuzzy}
+)
+
+// relevance collects the score terms of fuzzy matches.
+type relevance struct {
+	terms []string
+	args  []interface{}
+}
+
+// patientSearchFields is the search whitelist. Names and email are backed
+// by pg_trgm indexes (see database.createSearchIndexes), so prefix and
+// contains matches stay indexed.
+var patientSearchFields = []patientSearchField{
+	{name: "national_id", column: "national_id", modes: identifierModes},
+	{name: "patient_hn", column: "patient_hn", modes: identifierModes},
+	{name: "passport_id", column: "passport_id", modes: identifierModes},
+	{name: "phone_number", column: "phone_number", modes: identifierModes},
+	{name: "first_name_th", column: "first_name_th", modes: fuzzyNameModes, keyColumn: "first_name_th_key", key: phonetic.ThaiKey},
+	{name: "middle_name_th", column: "middle_name_th", modes: nameModes},
+	{name: "last_name_th", column: "last_name_th", modes: fuzzyNameModes, keyColumn: "last_name_th_key", key: phonetic.ThaiKey},
+	{name: "first_name_en", column: "first_name_en", modes: fuzzyNameModes, keyColumn: "first_name_en_key", key: phonetic.Soundex},
+	{name: "middle_name_en", column: "middle_name_en", modes: nameModes},
+	{name: "last_name_en", column: "last_name_en", modes: fuzzyNameModes, keyColumn: "last_name_en_key", key: phonetic.Soundex},
+	{name: "email", column: "email", modes: nameModes},
+}
+
+// matchModes returns the modes field may be searched with by the caller
+// view is for. Fields the caller cannot see in full only match whole values.
+func (field patientSearchField) matchModes(view *PatientPresenter) []models.MatchMode {
+	if view.sees(field.name) {
+		return field.modes
+	}
+	var modes []models.MatchMode
+	for _, mode := range wholeValueModes {
+		if containsMode(field.modes, mode) {
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+}
+
+func findPatientSearchField(name string) (patientSearchField, bool) {
+	for _, field := range patientSearchFields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return patientSearchField{}, false
+}
+
+// searchParams maps criteria onto patientSearchFields, normalizing
+// identifiers the way they are stored.
+func searchParams(criteria *models.PatientSearchCriteria) map[string]string {
+	return map[string]string{
+		"national_id":    criteria.NationalID,
+		"patient_hn":     criteria.PatientHN,
+		"first_name_th":  criteria.FirstNameTH,
+		"middle_name_th": criteria.MiddleNameTH,
+		"last_name_th":   criteria.LastNameTH,
+		"first_name_en":  criteria.FirstNameEN,
+		"middle_name_en": criteria.MiddleNameEN,
+		"last_name_en":   criteria.LastNameEN,
+		"passport_id":    validation.NormalizePassport(criteria.PassportID),
+		"phone_number":   validation.NormalizeThaiMobile(criteria.PhoneNumber),
+		"email":          criteria.Email,
+	}
+}
+
+// applyPatientFilters adds a condition for every non-empty value in params
+// using the field's default mode or the override in match, as far as view
+// allows. Fuzzy matches also contribute to the returned relevance.
+func applyPatientFilters(query *gorm.DB, params map[string]string, match map[string]models.MatchMode, view *PatientPresenter) (*gorm.DB, *relevance, error) {
+	for name := range params {
+		if _, ok := findPatientSearchField(name); !ok {
+			return nil, nil, fmt.Errorf("unknown patient search field %q", name)
+		}
+	}
+
+	var fieldErrs validation.Errors
+	for name, mode := range match {
+		field, ok := findPatientSearchField(name)
+		if !ok {
+			fieldErrs = append(fieldErrs, models.FieldError{Field: "match." + name, Message: "is not a searchable field"})
+			continue
+		}
+		if modes := field.matchModes(view); !containsMode(modes, mode) {
+			fieldErrs = append(fieldErrs, models.FieldError{Field: "match." + name, Message: "must be one of: " + joinModes(modes)})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return nil, nil, fieldErrs
+	}
+
+	rank := &relevance{}
+
+	for _, field := range patientSearchFields {
+		value := strings.TrimSpace(params[field.name])
+		if value == "" {
+			continue
+		}
+		mode := field.matchModes(view)[0]
+		if override, ok := match[field.name]; ok {
+			mode = override
+		}
+
+		switch mode {
+		case models.MatchExact:
+			query = query.Where(field.column+" = ?", value)
+		case models.MatchIExact:
+			query = query.Where(field.column+" ILIKE ?", escapeLike(value))
+		case models.MatchPrefix:
+			query = query.Where(field.column+" ILIKE ?", escapeLike(value)+"%")
+		case models.MatchContains:
+			query = query.Where(field.column+" ILIKE ?", "%"+escapeLike(value)+"%")
+		case models.MatchFuzzy:
+			query = fuzzyMatch(query, rank, field, value)
+		}
+	}
+	return query, rank, nil
+}
+
+// fuzzyMatch accepts names similar to value by trigrams (the pg_trgm %
+// operator) or with the same phonetic key.
+func fuzzyMatch(query *gorm.DB, rank *relevance, field patientSearchField, value string) *gorm.DB {
+	key := field.key(value)
+	if key == "" {
+		rank.terms = append(rank.terms, "similarity("+field.column+", ?)")
+		rank.args = append(rank.args, value)
+		return query.Where(field.column+" % ?", value)
+	}
+
+	rank.terms = append(rank.terms,
+		"GREATEST(similarity("+field.column+", ?), CASE WHEN "+field.keyColumn+" = ? THEN ?::float8 ELSE 0 END)")
+	rank.args = append(rank.args, value, key, phoneticMatchScore)
+	return query.Where("("+field.column+" % ? OR "+field.keyColumn+" = ?)", value, key)
+}
+
+func containsMode(modes []models.MatchMode, mode models.MatchMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func joinModes(modes []models.MatchMode) string {
+	names := make([]string, len(modes))
+	for i, m := range modes {
+		names[i] = string(m)
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 20
+)
+
+// ErrSuggestTimeout reports a suggestion query that ran past its budget.
+var ErrSuggestTimeout = errors.New("patient suggestions timed out")
+
+// SuggestPatients returns up to limit patients of hospitalID whose HN or
+// names start with q. Terms with digits match the HN; otherwise one term
+// matches a first or last name and two terms match the first and last
+// name, for callers who may see names. The query is cancelled after the
+// suggestion budget. Suggestions are shaped by view.
+func (s *PatientService) SuggestPatients(ctx context.Context, hospitalID, q string, limit int, view *PatientPresenter) ([]models.PatientSuggestion, error) {
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.suggestBudget)
+	defer cancel()
+
+	query := s.db.WithContext(ctx).Model(&models.UserPatient{}).
+		Select("id", "national_id", "patient_hn", "first_name_th", "middle_name_th", "last_name_th",
+			"first_name_en", "middle_name_en", "last_name_en", "date_of_birth").
+		Where("hospital_id = ?", hospitalID)
+
+	// Every condition is lower(column) LIKE 'term%' so that it can use the
+	// prefix indexes created by database.createSearchIndexes.
+	prefix := func(term string) string { return strings.ToLower(escapeLike(term)) + "%" }
+	terms := strings.Fields(q)
+	switch {
+	case strings.ContainsAny(q, "0123456789"):
+		query = query.Where("lower(patient_hn) LIKE ?", prefix(strings.Join(terms, ""))).
+			Order("patient_hn")
+	case !view.sees("first_name_th"):
+		return nil, fieldError("q", "must be an HN; name suggestions need access to patient names")
+	default:
+		suffix := "_en"
+		if isThai(q) {
+			suffix = "_th"
+		}
+		first, last := "first_name"+suffix, "last_name"+suffix
+		if len(terms) == 1 {
+			query = query.Where("(lower("+first+") LIKE ? OR lower("+last+") LIKE ?)", prefix(terms[0]), prefix(terms[0]))
+		} else {
+			query = query.Where("lower("+first+") LIKE ? AND lower("+last+") LIKE ?", prefix(terms[0]), prefix(terms[len(terms)-1]))
+		}
+		query = query.Order(first).Order(last)
+	}
+
+	start := time.Now()
+	var patients []models.UserPatient
+	err := query.Order("patient_hn").Limit(limit).Find(&patients).Error
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("Patient suggestions for hospital %s exceeded the %v budget", hospitalID, s.suggestBudget)
+			return nil, ErrSuggestTimeout
+		}
+		return nil, fmt.Errorf("failed to query database: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > s.suggestBudget/2 {
+		log.Printf("Slow patient suggestions for hospital %s: %v of the %v budget", hospitalID, elapsed, s.suggestBudget)
+	}
+
+	suggestions := make([]models.PatientSuggestion, len(patients))
+	for i := range patients {
+		suggestions[i] = view.Suggestion(&patients[i])
+	}
+	return suggestions, nil
+}
+
+func displayName(parts ...string) string {
+	var names []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			names = append(names, part)
+		}
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hospital-api/database"
+	"hospital-api/internal/models"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	benchHospitalID = "BENCH"
+	benchPatients   = 50000
+)
+
+// BenchmarkSuggestPatients measures typeahead suggestions against a real
+// PostgreSQL database, which TEST_DATABASE_DSN points at, e.g.
+//
+//	TEST_DATABASE_DSN="host=localhost user=postgres password=postgres dbname=hospital_test sslmode=disable" \
+//		go test ./internal/services -run '^$' -bench SuggestPatients
+//
+// Use a throwaway database: the schema is migrated with database.InitSchema,
+// which also seeds the mock data. The benchmark patients are registered in
+// a hospital of their own and removed afterwards.
+func BenchmarkSuggestPatients(b *testing.B) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		b.Skip("TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	if err := database.InitSchema(db); err != nil {
+		b.Fatalf("migrate: %v", err)
+	}
+	seedBenchPatients(b, db)
+
+	s := &PatientService{db: db, suggestBudget: time.Second}
+	view, err := NewPatientPresenter(models.RoleClerk, false, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	queries := []struct{ name, q string }{
+		{"hn", "BN0012"},
+		{"name_th", "สม"},
+		{"name_en", "som"},
+		{"first_and_last_th", "สม ใจ"},
+		{"first_and_last_en", "wi ken"},
+	}
+	for _, query := range queries {
+		b.Run(query.name, func(b *testing.B) {
+			ctx := context.Background()
+			for i := 0; i < b.N; i++ {
+				if _, err := s.SuggestPatients(ctx, benchHospitalID, query.q, defaultSuggestLimit, view); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// seedBenchPatients registers benchPatients patients with combinations of
+// common Thai and English names in the benchmark hospital.
+func seedBenchPatients(b *testing.B, db *gorm.DB) {
+	b.Helper()
+	cleanup := func() {
+		db.Where("hospital_id = ?", benchHospitalID).Delete(&models.UserPatient{})
+		db.Delete(&models.Hospital{ID: benchHospitalID})
+	}
+	cleanup()
+	b.Cleanup(cleanup)
+
+	if err := db.Create(&models.Hospital{ID: benchHospitalID, Name: "Benchmark hospital"}).Error; err != nil {
+		b.Fatalf("create hospital: %v", err)
+	}
+
+	firstTH := []string{"สมชาย", "สมหญิง", "วิชัย", "วันทนา", "ประพจน์", "สุภาพร", "กมล", "ธนา", "นิภา", "อรุณ"}
+	lastTH := []string{"ใจดี", "สวยงาม", "เก่งมาก", "รักดี", "สมประสงค์", "เรียนดี", "ศรีสุข", "มั่นคง", "ทองดี", "แสงทอง"}
+	firstEN := []string{"Somchai", "Somying", "Wichai", "Wantana", "Prapot", "Supaporn", "Kamol", "Thana", "Nipa", "Arun"}
+	lastEN := []string{"Jaidee", "Suaynam", "Kengmak", "Rakdee", "Somprasong", "Riandee", "Srisuk", "Mankong", "Thongdee", "Saengthong"}
+
+	patients := make([]models.UserPatient, 0, 1000)
+	for i := 0; i < benchPatients; i++ {
+		f, l := i%len(firstTH), (i/len(firstTH))%len(lastTH)
+		patients = append(patients, models.UserPatient{
+			NationalID:  fmt.Sprintf("9%012d", i),
+			PatientHN:   fmt.Sprintf("BN%06d", i),
+			FirstNameTH: firstTH[f],
+			LastNameTH:  fmt.Sprintf("%s%d", lastTH[l], i/100),
+			FirstNameEN: firstEN[f],
+			LastNameEN:  fmt.Sprintf("%s%d", lastEN[l], i/100),
+			DateOfBirth: time.Date(1940+i%80, time.Month(1+i%12), 1+i%28, 0, 0, 0, 0, time.UTC),
+			Gender:      models.Male,
+			HospitalID:  benchHospitalID,
+		})
+		if len(patients) == cap(patients) || i == benchPatients-1 {
+			if err := db.Omit("Hospital").CreateInBatches(patients, len(patients)).Error; err != nil {
+				b.Fatalf("create patients: %v", err)
+			}
+			patients = patients[:0]
+		}
+	}
+	if err := db.Exec("ANALYZE user_patients").Error; err != nil {
+		b.Fatalf("analyze: %v", err)
+	}
+}
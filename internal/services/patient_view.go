@@ -0,0 +1,245 @@
+package services
+
+import (
+	"hospital-api/internal/models"
+	"strings"
+	"time"
+)
+
+// piiAccess is how much of a personal data field a caller may see.
+type piiAccess int
+
+const (
+	piiOmitted piiAccess = iota
+	piiMasked
+	piiFull
+)
+
+// piiFields are the personal data fields that can be masked, and unmasked
+// through UnmaskPatient.
+var piiFields = []string{"national_id", "passport_id", "phone_number", "email"}
+
+// Personal data classes of piiPolicies that have no mask and are either
+// shown or left out.
+const (
+	piiName        = "name"
+	piiDateOfBirth = "date_of_birth"
+)
+
+// piiPolicies sets what each role sees of the personal data fields.
+// Identifiers are masked for everyone; the full values are only revealed
+// through UnmaskPatient, which is audited. Auditors see no personal data,
+// only the record references. Roles not listed, service accounts included,
+// fall back to servicePIIPolicy.
+var piiPolicies = map[models.Role]map[string]piiAccess{
+	models.RoleAdmin:   {piiName: piiFull, piiDateOfBirth: piiFull, "national_id": piiMasked, "passport_id": piiMasked, "phone_number": piiFull, "email": piiFull},
+	models.RoleDoctor:  {piiName: piiFull, piiDateOfBirth: piiFull, "national_id": piiMasked, "passport_id": piiMasked, "phone_number": piiFull, "email": piiFull},
+	models.RoleNurse:   {piiName: piiFull, piiDateOfBirth: piiFull, "national_id": piiMasked, "passport_id": piiMasked, "phone_number": piiFull, "email": piiFull},
+	models.RoleClerk:   {piiName: piiFull, piiDateOfBirth: piiFull, "national_id": piiMasked, "passport_id": piiMasked, "phone_number": piiMasked, "email": piiMasked},
+	models.RoleAuditor: {},
+}
+
+// servicePIIPolicy lets integrations match patients by masked identifiers
+// without exposing contact details.
+var servicePIIPolicy = map[string]piiAccess{piiName: piiFull, piiDateOfBirth: piiFull, "national_id": piiMasked, "passport_id": piiMasked}
+
+// patientViewField is one field of a PatientView. Empty values of
+// omitEmpty fields are left out, as the UserPatient JSON does. pii names
+// the piiPolicies entry that governs the field; fields without a mask are
+// left out unless the caller may see them in full.
+type patientViewField struct {
+	name      string
+	omitEmpty bool
+	pii       string
+	value     func(p *models.UserPatient) interface{}
+	mask      func(string) string
+}
+
+var patientViewFields = []patientViewField{
+	{name: "id", value: func(p *models.UserPatient) interface{} { return p.ID }},
+	{name: "national_id", pii: "national_id", value: func(p *models.UserPatient) interface{} { return p.NationalID }, mask: maskNationalID},
+	{name: "patient_hn", value: func(p *models.UserPatient) interface{} { return p.PatientHN }},
+	{name: "first_name_th", pii: piiName, value: func(p *models.UserPatient) interface{} { return p.FirstNameTH }},
+	{name: "middle_name_th", omitEmpty: true, pii: piiName, value: func(p *models.UserPatient) interface{} { return p.MiddleNameTH }},
+	{name: "last_name_th", pii: piiName, value: func(p *models.UserPatient) interface{} { return p.LastNameTH }},
+	{name: "first_name_en", pii: piiName, value: func(p *models.UserPatient) interface{} { return p.FirstNameEN }},
+	{name: "middle_name_en", omitEmpty: true, pii: piiName, value: func(p *models.UserPatient) interface{} { return p.MiddleNameEN }},
+	{name: "last_name_en", pii: piiName, value: func(p *models.UserPatient) interface{} { return p.LastNameEN }},
+	{name: "date_of_birth", pii: piiDateOfBirth, value: func(p *models.UserPatient) interface{} { return p.DateOfBirth }},
+	{name: "passport_id", omitEmpty: true, pii: "passport_id", value: func(p *models.UserPatient) interface{} { return optionalValue(p.PassportID) }, mask: maskPassport},
+	{name: "phone_number", omitEmpty: true, pii: "phone_number", value: func(p *models.UserPatient) interface{} { return p.PhoneNumber }, mask: maskPhone},
+	{name: "email", omitEmpty: true, pii: "email", value: func(p *models.UserPatient) interface{} { return p.Email }, mask: maskEmail},
+	{name: "gender", value: func(p *models.UserPatient) interface{} { return p.Gender }},
+	{name: "hospital_id", value: func(p *models.UserPatient) interface{} { return p.HospitalID }},
+}
+
+// PatientPresenter shapes patients for one caller: it applies the caller's
+// personal data policy and the fields they asked for.
+type PatientPresenter struct {
+	access map[string]piiAccess
+	fields map[string]bool
+}
+
+// NewPatientPresenter builds the presenter for a caller with role, or for a
+// service account when isService is set. fields is the comma-separated
+// fields= projection; empty selects every field.
+func NewPatientPresenter(role models.Role, isService bool, fields string) (*PatientPresenter, error) {
+	access := servicePIIPolicy
+	if policy, ok := piiPolicies[role]; ok && !isService {
+		access = policy
+	}
+	return newPatientPresenter(access, fields)
+}
+
+func newPatientPresenter(access map[string]piiAccess, fields string) (*PatientPresenter, error) {
+	p := &PatientPresenter{access: access}
+	if strings.TrimSpace(fields) == "" {
+		return p, nil
+	}
+
+	p.fields = make(map[string]bool)
+	for _, name := range strings.Split(fields, ",") {
+		name = strings.TrimSpace(name)
+		if !isPatientViewField(name) {
+			return nil, fieldError("fields", "contains unknown field '"+name+"'; must be among: "+patientViewFieldNames())
+		}
+		p.fields[name] = true
+	}
+	return p, nil
+}
+
+// Patient returns the view of patient.
+func (p *PatientPresenter) Patient(patient *models.UserPatient) models.PatientView {
+	view := make(models.PatientView, len(patientViewFields))
+	for _, field := range patientViewFields {
+		if p.fields != nil && !p.fields[field.name] {
+			continue
+		}
+		value := field.value(patient)
+		if field.omitEmpty && value == "" {
+			continue
+		}
+		if field.pii != "" {
+			switch p.access[field.pii] {
+			case piiOmitted:
+				continue
+			case piiMasked:
+				if field.mask == nil {
+					continue
+				}
+				value = field.mask(value.(string))
+			}
+		}
+		view[field.name] = value
+	}
+	return view
+}
+
+// Match returns the view of a search result, with its score.
+func (p *PatientPresenter) Match(match *models.PatientMatch) models.PatientView {
+	view := p.Patient(&match.UserPatient)
+	if match.Score != 0 && (p.fields == nil || p.fields["score"]) {
+		view["score"] = match.Score
+	}
+	return view
+}
+
+// Suggestion returns the typeahead summary of patient, built from its view
+// so that it shows no more than a search result would.
+func (p *PatientPresenter) Suggestion(patient *models.UserPatient) models.PatientSuggestion {
+	view := p.Patient(patient)
+	text := func(name string) string {
+		s, _ := view[name].(string)
+		return s
+	}
+
+	suggestion := models.PatientSuggestion{
+		ID:            patient.ID,
+		PatientHN:     patient.PatientHN,
+		NationalID:    text("national_id"),
+		DisplayNameTH: displayName(text("first_name_th"), text("middle_name_th"), text("last_name_th")),
+		DisplayNameEN: displayName(text("first_name_en"), text("middle_name_en"), text("last_name_en")),
+	}
+	if dob, ok := view["date_of_birth"].(time.Time); ok {
+		suggestion.DateOfBirth = dob.Format(dateFormat)
+	}
+	return suggestion
+}
+
+// sees reports whether the caller sees field in full. Searching a field
+// the caller only sees masked, or not at all, is limited to exact matches
+// so that its values cannot be enumerated.
+func (p *PatientPresenter) sees(name string) bool {
+	field, ok := findPatientViewField(name)
+	if !ok || field.pii == "" {
+		return true
+	}
+	return p.access[field.pii] == piiFull
+}
+
+func findPatientViewField(name string) (patientViewField, bool) {
+	for _, field := range patientViewFields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return patientViewField{}, false
+}
+
+func isPatientViewField(name string) bool {
+	if name == "score" {
+		return true
+	}
+	_, ok := findPatientViewField(name)
+	return ok
+}
+
+func patientViewFieldNames() string {
+	names := make([]string, 0, len(patientViewFields)+1)
+	for _, field := range patientViewFields {
+		names = append(names, field.name)
+	}
+	return strings.Join(append(names, "score"), " ")
+}
+
+func optionalValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// maskNationalID keeps the first five and last three digits of a national
+// ID in its printed grouping, e.g. 1-2345-xxxxx-12-3.
+func maskNationalID(id string) string {
+	if len(id) != 13 {
+		return strings.Repeat("x", len(id))
+	}
+	return id[0:1] + "-" + id[1:5] + "-xxxxx-" + id[10:12] + "-" + id[12:13]
+}
+
+// maskPassport keeps the first two and last two characters, e.g.
+// AAxxxxx01.
+func maskPassport(passport string) string {
+	if len(passport) <= 4 {
+		return strings.Repeat("x", len(passport))
+	}
+	return passport[:2] + strings.Repeat("x", len(passport)-4) + passport[len(passport)-2:]
+}
+
+// maskPhone keeps the prefix and the last four digits, e.g. 081-xxx-5678.
+func maskPhone(phone string) string {
+	if len(phone) != 10 {
+		return strings.Repeat("x", len(phone))
+	}
+	return phone[:3] + "-xxx-" + phone[6:]
+}
+
+// maskEmail keeps the first letter and the domain, e.g. s***@example.com.
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"hospital-api/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestMaskers(t *testing.T) {
+	tests := []struct {
+		name string
+		mask func(string) string
+		in   string
+		want string
+	}{
+		{"national ID", maskNationalID, "1234567890121", "1-2345-xxxxx-12-1"},
+		{"national ID of wrong length", maskNationalID, "12345", "xxxxx"},
+		{"empty national ID", maskNationalID, "", ""},
+		{"passport", maskPassport, "AA1000001", "AAxxxxx01"},
+		{"short passport", maskPassport, "AB12", "xxxx"},
+		{"five character passport", maskPassport, "AB123", "ABx23"},
+		{"phone", maskPhone, "0812345678", "081-xxx-5678"},
+		{"phone of wrong length", maskPhone, "021234567", "xxxxxxxxx"},
+		{"email", maskEmail, "somchai@example.com", "s***@example.com"},
+		{"email with @ in local part", maskEmail, `"a@b"@example.com`, `"***@example.com`},
+		{"email without local part", maskEmail, "@example.com", "***"},
+		{"not an email", maskEmail, "somchai", "***"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mask(tt.in); got != tt.want {
+				t.Errorf("mask(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatientPresenter(t *testing.T) {
+	passport := "AA1000001"
+	patient := &models.UserPatient{
+		ID:          7,
+		NationalID:  "1234567890121",
+		PatientHN:   "HN0001",
+		FirstNameTH: "สมชาย",
+		LastNameTH:  "ใจดี",
+		FirstNameEN: "Somchai",
+		LastNameEN:  "Jaidee",
+		DateOfBirth: date("1990-01-15"),
+		PassportID:  &passport,
+		PhoneNumber: "0812345678",
+		Email:       "somchai@example.com",
+		Gender:      models.Male,
+		HospitalID:  "H001",
+	}
+
+	tests := []struct {
+		name      string
+		role      models.Role
+		isService bool
+		fields    string
+		want      map[string]interface{}
+	}{
+		{
+			name: "doctor",
+			role: models.RoleDoctor,
+			want: map[string]interface{}{
+				"national_id": "1-2345-xxxxx-12-1", "passport_id": "AAxxxxx01",
+				"phone_number": "0812345678", "email": "somchai@example.com",
+				"first_name_th": "สมชาย", "date_of_birth": date("1990-01-15"),
+			},
+		},
+		{
+			name: "clerk",
+			role: models.RoleClerk,
+			want: map[string]interface{}{
+				"national_id": "1-2345-xxxxx-12-1", "passport_id": "AAxxxxx01",
+				"phone_number": "081-xxx-5678", "email": "s***@example.com",
+				"first_name_th": "สมชาย", "date_of_birth": date("1990-01-15"),
+			},
+		},
+		{
+			name: "auditor",
+			role: models.RoleAuditor,
+			want: map[string]interface{}{
+				"national_id": nil, "passport_id": nil, "phone_number": nil, "email": nil,
+				"first_name_th": nil, "last_name_en": nil, "date_of_birth": nil,
+			},
+		},
+		{
+			name:      "service account",
+			role:      models.RoleAdmin,
+			isService: true,
+			want: map[string]interface{}{
+				"national_id": "1-2345-xxxxx-12-1", "passport_id": "AAxxxxx01",
+				"phone_number": nil, "email": nil,
+				"first_name_th": "สมชาย", "date_of_birth": date("1990-01-15"),
+			},
+		},
+		{
+			name:   "projection",
+			role:   models.RoleDoctor,
+			fields: "patient_hn, email",
+			want: map[string]interface{}{
+				"id": nil, "patient_hn": "HN0001", "email": "somchai@example.com", "national_id": nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view, err := NewPatientPresenter(tt.role, tt.isService, tt.fields)
+			if err != nil {
+				t.Fatalf("NewPatientPresenter: %v", err)
+			}
+			got := view.Patient(patient)
+			if tt.fields == "" {
+				// References are shown to every caller.
+				if got["id"] != uint(7) || got["patient_hn"] != "HN0001" {
+					t.Errorf("id, patient_hn = %v, %v", got["id"], got["patient_hn"])
+				}
+			}
+			for field, want := range tt.want {
+				value, shown := got[field]
+				if want == nil {
+					if shown {
+						t.Errorf("%s = %v, want it left out", field, value)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(value, want) {
+					t.Errorf("%s = %v, want %v", field, value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewPatientPresenterUnknownField(t *testing.T) {
+	if _, err := NewPatientPresenter(models.RoleDoctor, false, "patient_hn,password"); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestPatientPresenterSuggestion(t *testing.T) {
+	patient := &models.UserPatient{
+		ID:           7,
+		NationalID:   "1234567890121",
+		PatientHN:    "HN0001",
+		FirstNameTH:  "สมชาย",
+		LastNameTH:   "ใจดี",
+		FirstNameEN:  "Somchai",
+		MiddleNameEN: "K.",
+		LastNameEN:   "Jaidee",
+		DateOfBirth:  date("1990-01-15"),
+	}
+
+	tests := []struct {
+		role models.Role
+		want models.PatientSuggestion
+	}{
+		{models.RoleNurse, models.PatientSuggestion{
+			ID: 7, PatientHN: "HN0001", NationalID: "1-2345-xxxxx-12-1",
+			DisplayNameTH: "สมชาย ใจดี", DisplayNameEN: "Somchai K. Jaidee", DateOfBirth: "1990-01-15",
+		}},
+		{models.RoleAuditor, models.PatientSuggestion{ID: 7, PatientHN: "HN0001"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			view, _ := NewPatientPresenter(tt.role, false, "")
+			if got := view.Suggestion(patient); got != tt.want {
+				t.Errorf("Suggestion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyPrefix = "hak_"
+	// lastUsedResolution limits how often a busy key writes last_used_at.
+	lastUsedResolution = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey          = errors.New("API key is invalid, expired or revoked")
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	ErrServiceAccountExists   = errors.New("a service account with this name already exists")
+	ErrAPIKeyNotFound         = errors.New("API key not found")
+)
+
+type ServiceAccountService struct {
+	db *gorm.DB
+}
+
+func NewServiceAccountService(db *gorm.DB) *ServiceAccountService {
+	return &ServiceAccountService{db: db}
+}
+
+func (s *ServiceAccountService) CreateServiceAccount(hospitalID string, adminID uint, req *models.CreateServiceAccountRequest) (*models.ServiceAccount, error) {
+	var count int64
+	err := s.db.Model(&models.ServiceAccount{}).Where("hospital_id = ? AND name = ?", hospitalID, req.Name).Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	if count > 0 {
+		return nil, ErrServiceAccountExists
+	}
+
+	account := &models.ServiceAccount{
+		Name:        req.Name,
+		Description: req.Description,
+		HospitalID:  hospitalID,
+		CreatedByID: adminID,
+	}
+	if err := s.db.Create(account).Error; err != nil {
+		return nil, fmt.Errorf("failed to create service account: %v", err)
+	}
+
+	log.Printf("SECURITY: admin %d created service account '%s' in hospital %s", adminID, account.Name, hospitalID)
+	return account, nil
+}
+
+func (s *ServiceAccountService) ListServiceAccounts(hospitalID string) ([]models.ServiceAccount, error) {
+	var accounts []models.ServiceAccount
+	if err := s.db.Where("hospital_id = ?", hospitalID).Order("name ASC").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %v", err)
+	}
+	return accounts, nil
+}
+
+// DeactivateServiceAccount disables the account and revokes all its keys.
+func (s *ServiceAccountService) DeactivateServiceAccount(hospitalID string, id, adminID uint) error {
+	account, err := s.getServiceAccount(hospitalID, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(account).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.APIKey{}).
+			Where("service_account_id = ? AND revoked_at IS NULL", account.ID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deactivate service account: %v", err)
+	}
+
+	log.Printf("SECURITY: admin %d deactivated service account '%s'", adminID, account.Name)
+	return nil
+}
+
+// IssueAPIKey creates a key for the service account and returns the
+// plaintext key, which is never stored and cannot be retrieved again.
+func (s *ServiceAccountService) IssueAPIKey(hospitalID string, accountID, adminID uint, scopes []string, ttl time.Duration) (string, *models.APIKey, error) {
+	account, err := s.getServiceAccount(hospitalID, accountID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !account.IsActive {
+		return "", nil, ErrServiceAccountNotFound
+	}
+
+	key, record, err := newAPIKey(account.ID, adminID, scopes, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to store API key: %v", err)
+	}
+
+	log.Printf("SECURITY: admin %d issued API key %s for service account '%s'", adminID, record.Prefix, account.Name)
+	return key, record, nil
+}
+
+func (s *ServiceAccountService) ListAPIKeys(hospitalID string, accountID uint) ([]models.APIKey, error) {
+	if _, err := s.getServiceAccount(hospitalID, accountID); err != nil {
+		return nil, err
+	}
+
+	var keys []models.APIKey
+	if err := s.db.Where("service_account_id = ?", accountID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %v", err)
+	}
+	return keys, nil
+}
+
+// RotateAPIKey issues a replacement with the same scopes and lifetime and
+// lets the old key expire after grace.
+func (s *ServiceAccountService) RotateAPIKey(hospitalID string, accountID, keyID, adminID uint, grace time.Duration) (string, *models.APIKey, error) {
+	account, err := s.getServiceAccount(hospitalID, accountID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !account.IsActive {
+		return "", nil, ErrServiceAccountNotFound
+	}
+
+	old, err := s.getAPIKey(account.ID, keyID)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	if !old.IsUsable(now) {
+		return "", nil, ErrAPIKeyNotFound
+	}
+
+	var ttl time.Duration
+	if old.ExpiresAt != nil {
+		ttl = old.ExpiresAt.Sub(old.CreatedAt)
+	}
+	key, record, err := newAPIKey(account.ID, adminID, old.ScopeList(), ttl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		if grace <= 0 {
+			return tx.Model(old).Update("revoked_at", now).Error
+		}
+		graceEnd := now.Add(grace)
+		if old.ExpiresAt != nil && old.ExpiresAt.Before(graceEnd) {
+			return nil
+		}
+		return tx.Model(old).Update("expires_at", graceEnd).Error
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to rotate API key: %v", err)
+	}
+
+	log.Printf("SECURITY: admin %d rotated API key %s to %s for service account '%s'", adminID, old.Prefix, record.Prefix, account.Name)
+	return key, record, nil
+}
+
+func (s *ServiceAccountService) RevokeAPIKey(hospitalID string, accountID, keyID, adminID uint) error {
+	if _, err := s.getServiceAccount(hospitalID, accountID); err != nil {
+		return err
+	}
+
+	result := s.db.Model(&models.APIKey{}).
+		Where("id = ? AND service_account_id = ? AND revoked_at IS NULL", keyID, accountID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke API key: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	log.Printf("SECURITY: admin %d revoked API key %d", adminID, keyID)
+	return nil
+}
+
+// AuthenticateAPIKey resolves a presented key to its active service account.
+func (s *ServiceAccountService) AuthenticateAPIKey(key string) (*models.APIKey, *models.ServiceAccount, error) {
+	prefix, ok := parseAPIKeyPrefix(key)
+	if !ok {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var record models.APIKey
+	if err := s.db.Where("prefix = ?", prefix).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, fmt.Errorf("database error: %v", err)
+	}
+
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(record.KeyHash), []byte(hashToken(key))) != 1 || !record.IsUsable(now) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var account models.ServiceAccount
+	if err := s.db.First(&account, record.ServiceAccountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, fmt.Errorf("database error: %v", err)
+	}
+	if !account.IsActive {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) > lastUsedResolution {
+		if err := s.db.Model(&record).Update("last_used_at", now).Error; err != nil {
+			log.Printf("Failed to record use of API key %s: %v", record.Prefix, err)
+		}
+	}
+
+	return &record, &account, nil
+}
+
+func (s *ServiceAccountService) getServiceAccount(hospitalID string, id uint) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := s.db.Where("id = ? AND hospital_id = ?", id, hospitalID).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrServiceAccountNotFound
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return &account, nil
+}
+
+func (s *ServiceAccountService) getAPIKey(accountID, keyID uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.Where("id = ? AND service_account_id = ?", keyID, accountID).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return &key, nil
+}
+
+func newAPIKey(accountID, adminID uint, scopes []string, ttl time.Duration) (string, *models.APIKey, error) {
+	for _, scope := range scopes {
+		if !containsString(models.APIKeyScopes, scope) {
+			return "", nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	prefixBytes := make([]byte, 4)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %v", err)
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %v", err)
+	}
+
+	prefix := apiKeyPrefix + hex.EncodeToString(prefixBytes)
+	key := prefix + "_" + secret
+
+	record := &models.APIKey{
+		ServiceAccountID: accountID,
+		Prefix:           prefix,
+		KeyHash:          hashToken(key),
+		Scopes:           strings.Join(scopes, ","),
+		CreatedByID:      adminID,
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		record.ExpiresAt = &expiresAt
+	}
+	return key, record, nil
+}
+
+// parseAPIKeyPrefix returns the "hak_xxxxxxxx" part of a key.
+func parseAPIKeyPrefix(key string) (string, bool) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", false
+	}
+	i := strings.Index(key[len(apiKeyPrefix):], "_")
+	if i <= 0 {
+		return "", false
+	}
+	return key[:len(apiKeyPrefix)+i], true
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"hospital-api/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestParseAPIKeyPrefix(t *testing.T) {
+	tests := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{"hak_0123abcd_secret", "hak_0123abcd", true},
+		{"hak_0123abcd_sec_ret", "hak_0123abcd", true},
+		{"hak_0123abcd", "", false},
+		{"hak__secret", "", false},
+		{"hak_", "", false},
+		{"", "", false},
+		{"Bearer hak_0123abcd_secret", "", false},
+		{"HAK_0123abcd_secret", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseAPIKeyPrefix(tt.key)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseAPIKeyPrefix(%q) = %q, %v; want %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNewAPIKey(t *testing.T) {
+	key, record, err := newAPIKey(3, 9, []string{models.ScopePatientRead}, time.Hour)
+	if err != nil {
+		t.Fatalf("newAPIKey: %v", err)
+	}
+	prefix, ok := parseAPIKeyPrefix(key)
+	if !ok || prefix != record.Prefix || len(prefix) != len(apiKeyPrefix)+8 {
+		t.Errorf("key %q has prefix %q, record prefix %q", key, prefix, record.Prefix)
+	}
+	if record.KeyHash != hashToken(key) || strings.Contains(record.KeyHash, key) {
+		t.Error("record does not hold the hash of the key")
+	}
+	if record.ServiceAccountID != 3 || record.CreatedByID != 9 || record.Scopes != models.ScopePatientRead {
+		t.Errorf("record = %+v", record)
+	}
+	if record.ExpiresAt == nil || time.Until(*record.ExpiresAt) > time.Hour || time.Until(*record.ExpiresAt) < 59*time.Minute {
+		t.Errorf("expires at %v, want in an hour", record.ExpiresAt)
+	}
+
+	if _, record, _ := newAPIKey(3, 9, nil, 0); record.ExpiresAt != nil {
+		t.Errorf("key without TTL expires at %v", record.ExpiresAt)
+	}
+	if _, _, err := newAPIKey(3, 9, []string{"patient:write"}, 0); err == nil {
+		t.Error("newAPIKey accepted an unknown scope")
+	}
+}
+
+// createTestServiceAccount creates an active service account in hospitalID.
+func createTestServiceAccount(t *testing.T, db *gorm.DB, hospitalID, name string) *models.ServiceAccount {
+	t.Helper()
+	account := &models.ServiceAccount{Name: name, HospitalID: hospitalID, IsActive: true}
+	if err := db.Create(account).Error; err != nil {
+		t.Fatalf("create service account: %v", err)
+	}
+	return account
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		present func(key string) string
+		prepare func(db *gorm.DB, account *models.ServiceAccount, record *models.APIKey)
+		ok      bool
+	}{
+		{name: "valid", ok: true},
+		{name: "wrong secret", present: func(key string) string {
+			if strings.HasSuffix(key, "x") {
+				return key[:len(key)-1] + "y"
+			}
+			return key[:len(key)-1] + "x"
+		}},
+		{name: "unknown prefix", present: func(key string) string { return "hak_00000000_" + key[len("hak_00000000_"):] }},
+		{name: "malformed", present: func(key string) string { return strings.TrimPrefix(key, apiKeyPrefix) }},
+		{name: "revoked", prepare: func(db *gorm.DB, account *models.ServiceAccount, record *models.APIKey) {
+			db.Model(record).Update("revoked_at", time.Now())
+		}},
+		{name: "expired", prepare: func(db *gorm.DB, account *models.ServiceAccount, record *models.APIKey) {
+			db.Model(record).Update("expires_at", time.Now().Add(-time.Second))
+		}},
+		{name: "account deactivated", prepare: func(db *gorm.DB, account *models.ServiceAccount, record *models.APIKey) {
+			db.Model(account).Update("is_active", false)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			createTestHospitals(t, db, "KEY1")
+			s := NewServiceAccountService(db)
+			account := createTestServiceAccount(t, db, "KEY1", "lab")
+
+			key, record, err := s.IssueAPIKey("KEY1", account.ID, 1, []string{models.ScopePatientRead}, time.Hour)
+			if err != nil {
+				t.Fatalf("IssueAPIKey: %v", err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(db, account, record)
+			}
+			presented := key
+			if tt.present != nil {
+				presented = tt.present(key)
+			}
+
+			gotKey, gotAccount, err := s.AuthenticateAPIKey(presented)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidAPIKey) {
+					t.Errorf("AuthenticateAPIKey = %v, want ErrInvalidAPIKey", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AuthenticateAPIKey: %v", err)
+			}
+			if gotKey.ID != record.ID || gotAccount.ID != account.ID || gotAccount.HospitalID != "KEY1" {
+				t.Errorf("authenticated key %d of account %d in %s", gotKey.ID, gotAccount.ID, gotAccount.HospitalID)
+			}
+
+			var stored models.APIKey
+			if err := db.First(&stored, record.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.LastUsedAt == nil {
+				t.Error("last_used_at was not recorded")
+			}
+		})
+	}
+}
+
+func TestRotateAPIKeyGraceWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		grace     time.Duration
+		oldWorks  bool
+		oldExpiry time.Duration // expected remaining lifetime of the old key, 0 for unchanged
+	}{
+		{name: "no grace", ttl: 24 * time.Hour, grace: 0, oldWorks: false},
+		{name: "grace window", ttl: 24 * time.Hour, grace: time.Hour, oldWorks: true, oldExpiry: time.Hour},
+		{name: "grace past the expiry", ttl: 30 * time.Minute, grace: time.Hour, oldWorks: true},
+		{name: "key without expiry", ttl: 0, grace: time.Hour, oldWorks: true, oldExpiry: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			createTestHospitals(t, db, "KEY1")
+			s := NewServiceAccountService(db)
+			account := createTestServiceAccount(t, db, "KEY1", "kiosk")
+
+			oldKey, old, err := s.IssueAPIKey("KEY1", account.ID, 1, []string{models.ScopePatientRead}, tt.ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newKey, replacement, err := s.RotateAPIKey("KEY1", account.ID, old.ID, 1, tt.grace)
+			if err != nil {
+				t.Fatalf("RotateAPIKey: %v", err)
+			}
+
+			if _, _, err := s.AuthenticateAPIKey(newKey); err != nil {
+				t.Errorf("new key: %v", err)
+			}
+			if replacement.Scopes != old.Scopes {
+				t.Errorf("new key scopes %q, want %q", replacement.Scopes, old.Scopes)
+			}
+			if (replacement.ExpiresAt == nil) != (tt.ttl == 0) {
+				t.Errorf("new key expires at %v, want a lifetime of %v", replacement.ExpiresAt, tt.ttl)
+			}
+
+			_, _, err = s.AuthenticateAPIKey(oldKey)
+			if tt.oldWorks != (err == nil) {
+				t.Errorf("old key right after rotation: %v, want working %v", err, tt.oldWorks)
+			}
+
+			var stored models.APIKey
+			if err := db.First(&stored, old.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.oldExpiry > 0:
+				if stored.ExpiresAt == nil || time.Until(*stored.ExpiresAt) > tt.oldExpiry || time.Until(*stored.ExpiresAt) < tt.oldExpiry-time.Minute {
+					t.Errorf("old key expires at %v, want in %v", stored.ExpiresAt, tt.oldExpiry)
+				}
+			case tt.grace > 0:
+				// The database keeps microseconds.
+				if stored.ExpiresAt == nil || stored.ExpiresAt.Sub(*old.ExpiresAt).Abs() > time.Millisecond {
+					t.Errorf("old key expiry moved from %v to %v", old.ExpiresAt, stored.ExpiresAt)
+				}
+			}
+
+			// A rotated key cannot be rotated again once it is unusable.
+			if !tt.oldWorks {
+				if _, _, err := s.RotateAPIKey("KEY1", account.ID, old.ID, 1, tt.grace); !errors.Is(err, ErrAPIKeyNotFound) {
+					t.Errorf("rotating a revoked key = %v, want ErrAPIKeyNotFound", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxUserAgentLength = 255
+	// lastSeenResolution limits how often an active session writes
+	// last_seen_at.
+	lastSeenResolution = time.Minute
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ClientInfo describes the device a session was started or refreshed from.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+type SessionService struct {
+	db *gorm.DB
+}
+
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+// ListSessions returns the sessions of a staff member, newest first. A
+// non-empty hospitalID limits the list to sessions scoped to that hospital.
+func (s *SessionService) ListSessions(staffID uint, hospitalID string, includeInactive bool) ([]models.StaffSession, error) {
+	query := s.db.Where("staff_id = ?", staffID)
+	if hospitalID != "" {
+		query = query.Where("hospital_id = ?", hospitalID)
+	}
+	if !includeInactive {
+		query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	var sessions []models.StaffSession
+	if err := query.Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %v", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one session of a staff member. A non-empty hospitalID
+// restricts it to sessions scoped to that hospital.
+func (s *SessionService) RevokeSession(staffID uint, hospitalID, sessionID string) error {
+	query := s.db.Where("id = ? AND staff_id = ? AND revoked_at IS NULL", sessionID, staffID)
+	if hospitalID != "" {
+		query = query.Where("hospital_id = ?", hospitalID)
+	}
+
+	var session models.StaffSession
+	if err := query.First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	if err := revokeSessions(s.db, "id = ?", session.ID); err != nil {
+		return err
+	}
+
+	log.Printf("SECURITY: session %s of staff %d revoked", session.ID, staffID)
+	return nil
+}
+
+// RevokeAllSessions ends every session of a staff member, optionally only
+// those scoped to hospitalID, and returns how many were active.
+func (s *SessionService) RevokeAllSessions(staffID uint, hospitalID string) (int64, error) {
+	query := s.db.Model(&models.StaffSession{}).Where("staff_id = ? AND revoked_at IS NULL", staffID)
+	if hospitalID != "" {
+		query = query.Where("hospital_id = ?", hospitalID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	var err error
+	if hospitalID == "" {
+		err = revokeSessions(s.db, "staff_id = ?", staffID)
+	} else {
+		err = revokeSessions(s.db, "staff_id = ? AND hospital_id = ?", staffID, hospitalID)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("SECURITY: all sessions of staff %d revoked", staffID)
+	return count, nil
+}
+
+// revokeSessions marks the sessions matching the condition revoked together
+// with their refresh token families.
+func revokeSessions(db *gorm.DB, query string, args ...interface{}) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		families := tx.Model(&models.StaffSession{}).Select("family_id").Where(query, args...)
+		err := tx.Model(&models.RefreshToken{}).
+			Where("family_id IN (?) AND revoked_at IS NULL", families).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+		}
+
+		err = tx.Model(&models.StaffSession{}).
+			Where(query, args...).
+			Where("revoked_at IS NULL").
+			Update("revoked_at", now).Error
+		if err != nil {
+			return fmt.Errorf("failed to revoke sessions: %v", err)
+		}
+		return nil
+	})
+}
+
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) > maxUserAgentLength {
+		return strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	return userAgent
+}
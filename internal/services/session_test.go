@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"hospital-api/internal/models"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateUserAgent(t *testing.T) {
+	tests := []struct {
+		name  string
+		agent string
+		want  int
+	}{
+		{"short", "curl/8.0", len("curl/8.0")},
+		{"limit", strings.Repeat("a", maxUserAgentLength), maxUserAgentLength},
+		{"long", strings.Repeat("a", maxUserAgentLength+10), maxUserAgentLength},
+		// A three-byte rune straddling the limit is dropped, not split.
+		{"multibyte", strings.Repeat("a", maxUserAgentLength-1) + "ไทย", maxUserAgentLength - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateUserAgent(tt.agent)
+			if len(got) != tt.want || !utf8.ValidString(got) || !strings.HasPrefix(tt.agent, got) {
+				t.Errorf("truncateUserAgent returned %d bytes (valid UTF-8 %v), want a %d byte prefix", len(got), utf8.ValidString(got), tt.want)
+			}
+		})
+	}
+}
+
+// testSession is a session started by IssueTokenPair.
+type testSession struct {
+	id           string
+	accessToken  string
+	refreshToken string
+}
+
+// startTestSessions logs staff in once per hospital and returns the sessions
+// in the same order.
+func startTestSessions(t *testing.T, s *TokenService, staff *models.UserStaff, hospitals ...string) []testSession {
+	t.Helper()
+	sessions := make([]testSession, 0, len(hospitals))
+	for _, hospitalID := range hospitals {
+		scoped := *staff
+		scoped.HospitalID = hospitalID
+		pair, err := s.IssueTokenPair(&scoped, ClientInfo{IPAddress: "10.0.0.1", UserAgent: "test"})
+		if err != nil {
+			t.Fatalf("IssueTokenPair: %v", err)
+		}
+		claims, err := s.ValidateAccessToken(pair.Token)
+		if err != nil {
+			t.Fatalf("ValidateAccessToken: %v", err)
+		}
+		sessions = append(sessions, testSession{id: claims.SessionID, accessToken: pair.Token, refreshToken: pair.RefreshToken})
+	}
+	return sessions
+}
+
+func TestRevokeSessionRevokesRefreshFamily(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "SES1", "SES2")
+	tokens := newTestTokenService(t, db)
+	s := NewSessionService(db)
+	staff := createTestStaff(t, db, "session.revoke", "SES1", models.RoleDoctor)
+	other := createTestStaff(t, db, "session.other", "SES1", models.RoleDoctor)
+	if _, err := createMembership(db, staff.ID, "SES2", models.RoleDoctor, models.MembershipSourceAdmin); err != nil {
+		t.Fatal(err)
+	}
+	sessions := startTestSessions(t, tokens, staff, "SES1", "SES2")
+
+	// The refresh token was rotated once, so the family has two members.
+	rotated, err := tokens.Refresh(sessions[0].refreshToken, ClientInfo{IPAddress: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		staffID    uint
+		hospitalID string
+		sessionID  string
+	}{
+		{"other staff", other.ID, "", sessions[0].id},
+		{"other hospital", staff.ID, "SES2", sessions[0].id},
+		{"unknown session", staff.ID, "", "no-such-session"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.RevokeSession(tt.staffID, tt.hospitalID, tt.sessionID); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("RevokeSession = %v, want ErrSessionNotFound", err)
+			}
+		})
+	}
+
+	if err := s.RevokeSession(staff.ID, "SES1", sessions[0].id); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, err := tokens.ValidateAccessToken(sessions[0].accessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("access token of the revoked session = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := tokens.Refresh(rotated.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("refresh token of the revoked session still works")
+	}
+	family := refreshTokenRecord(t, db, sessions[0].refreshToken).FamilyID
+	var active int64
+	db.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", family).Count(&active)
+	if active != 0 {
+		t.Errorf("%d refresh tokens of the revoked family still active", active)
+	}
+
+	// The other session is untouched, and a revoked session cannot be
+	// revoked twice.
+	if _, err := tokens.ValidateAccessToken(sessions[1].accessToken); err != nil {
+		t.Errorf("access token of the other session: %v", err)
+	}
+	if refreshTokenRecord(t, db, sessions[1].refreshToken).RevokedAt != nil {
+		t.Error("refresh token of the other session was revoked")
+	}
+	if err := s.RevokeSession(staff.ID, "", sessions[0].id); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking again = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	tests := []struct {
+		name       string
+		hospitalID string
+		count      int64
+		revoked    []bool // per session in SES1, SES1, SES2
+	}{
+		{"all hospitals", "", 3, []bool{true, true, true}},
+		{"one hospital", "SES1", 2, []bool{true, true, false}},
+		{"hospital without sessions", "SES3", 0, []bool{false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			createTestHospitals(t, db, "SES1", "SES2", "SES3")
+			tokens := newTestTokenService(t, db)
+			s := NewSessionService(db)
+			staff := createTestStaff(t, db, "session.all", "SES1", models.RoleNurse)
+			if _, err := createMembership(db, staff.ID, "SES2", models.RoleNurse, models.MembershipSourceAdmin); err != nil {
+				t.Fatal(err)
+			}
+			bystander := createTestStaff(t, db, "session.bystander", "SES1", models.RoleNurse)
+			sessions := startTestSessions(t, tokens, staff, "SES1", "SES1", "SES2")
+			kept := startTestSessions(t, tokens, bystander, "SES1")[0]
+
+			count, err := s.RevokeAllSessions(staff.ID, tt.hospitalID)
+			if err != nil {
+				t.Fatalf("RevokeAllSessions: %v", err)
+			}
+			if count != tt.count {
+				t.Errorf("RevokeAllSessions = %d, want %d", count, tt.count)
+			}
+
+			for i, session := range sessions {
+				_, err := tokens.ValidateAccessToken(session.accessToken)
+				if revoked := errors.Is(err, ErrTokenRevoked); revoked != tt.revoked[i] {
+					t.Errorf("session %d: access token error %v, want revoked %v", i, err, tt.revoked[i])
+				}
+				if revoked := refreshTokenRecord(t, db, session.refreshToken).RevokedAt != nil; revoked != tt.revoked[i] {
+					t.Errorf("session %d: refresh token revoked %v, want %v", i, revoked, tt.revoked[i])
+				}
+			}
+			if _, err := tokens.ValidateAccessToken(kept.accessToken); err != nil {
+				t.Errorf("session of another staff member: %v", err)
+			}
+		})
+	}
+}
@@ -3,76 +3,453 @@ package services
 import (
 	"errors"
 	"fmt"
+	"hospital-api/internal/configs"
 	"hospital-api/internal/models"
 	"log"
+	"sync"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrStaffNotFound          = errors.New("staff not found")
+	ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+	ErrPasswordReused         = errors.New("password was used recently, choose a different one")
+	ErrInvalidResetToken      = errors.New("password reset token is invalid or expired")
+)
+
 type StaffService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	guard    *loginGuard
+	policy   PasswordPolicy
+	hasher   PasswordHasher
+	resetTTL time.Duration
+
+	dummyOnce sync.Once
+	dummyHash string
 }
 
-func NewStaffService(db *gorm.DB) *StaffService {
-	return &StaffService{db: db}
+func NewStaffService(db *gorm.DB, hasher PasswordHasher) *StaffService {
+	return &StaffService{
+		db:       db,
+		guard:    newLoginGuard(db, configs.Envs),
+		policy:   NewPasswordPolicy(configs.Envs),
+		hasher:   hasher,
+		resetTTL: time.Duration(configs.Envs.PasswordResetTokenMinutes) * time.Minute,
+	}
 }
 
 func (s *StaffService) CreateStaff(staff *models.UserStaff) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(staff.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(staff.Password)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %v", err)
+		return err
 	}
-	staff.Password = string(hashedPassword)
+	staff.Password = hashedPassword
 
-	if err := s.db.Create(staff).Error; err != nil {
-		return fmt.Errorf("failed to create staff: %v", err)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(staff).Error; err != nil {
+			return fmt.Errorf("failed to create staff: %v", err)
+		}
+		_, err := createMembership(tx, staff.ID, staff.HospitalID, staff.Role, models.MembershipSourceAdmin)
+		return err
+	})
+}
+
+// CreateStaffByAdmin creates a staff account in the admin's own hospital.
+// The admin chose the password, so the staff member must change it on the
+// first login.
+func (s *StaffService) CreateStaffByAdmin(hospitalID string, req *models.CreateStaffRequest) (*models.UserStaff, error) {
+	role := req.Role
+	if role == "" {
+		role = models.RoleClerk
 	}
-	return nil
+
+	if err := s.policy.Validate(req.Password, req.Username); err != nil {
+		return nil, err
+	}
+
+	var staff *models.UserStaff
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		staff, err = s.createStaffAccount(tx, req.Username, req.Password, role, hospitalID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(staff).Update("must_change_password", true).Error; err != nil {
+			return fmt.Errorf("failed to flag password change: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	staff.MustChangePassword = true
+	return staff, nil
 }
 
-func (s *StaffService) CreateStaffByRequest(req *models.CreateStaffRequest) (*models.UserStaff, error) {
-	var hospital models.Hospital
-	log.Printf("Looking for hospital: '%s' (length: %d)", req.HospitalName, len(req.HospitalName))
-	if err := s.db.Where("name = ?", req.HospitalName).First(&hospital).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("hospital not found: %s", req.HospitalName)
+// CreateStaffByInvitation consumes req.InvitationCode and creates the staff
+// account in the invitation's hospital with the invitation's role.
+func (s *StaffService) CreateStaffByInvitation(req *models.CreateStaffRequest) (*models.UserStaff, error) {
+	if err := s.policy.Validate(req.Password, req.Username); err != nil {
+		return nil, err
+	}
+
+	var staff *models.UserStaff
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		invitation, err := consumeInvitation(tx, req.InvitationCode)
+		if err != nil {
+			return err
 		}
-		return nil, fmt.Errorf("database error: %v", err)
+
+		staff, err = s.createStaffAccount(tx, req.Username, req.Password, invitation.Role, invitation.HospitalID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.StaffInvitation{}).Where("id = ?", invitation.ID).Update("used_by_id", staff.ID).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	log.Printf("Staff '%s' joined hospital %s by invitation", staff.Username, staff.HospitalID)
+	return staff, nil
+}
+
+// createStaffAccount creates the account together with its home hospital
+// membership. db should be a transaction.
+func (s *StaffService) createStaffAccount(db *gorm.DB, username, password string, role models.Role, hospitalID string) (*models.UserStaff, error) {
+	hashedPassword, err := s.hasher.Hash(password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %v", err)
+		return nil, err
 	}
 
 	staff := &models.UserStaff{
-		Username:   req.Username,
-		Password:   string(hashedPassword),
-		HospitalID: hospital.ID,
+		Username:   username,
+		Password:   hashedPassword,
+		Role:       role,
+		HospitalID: hospitalID,
 	}
 
-	if err := s.db.Create(staff).Error; err != nil {
+	if err := db.Create(staff).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrUsernameTaken
+		}
 		return nil, fmt.Errorf("failed to create staff: %v", err)
 	}
 
+	if _, err := createMembership(db, staff.ID, hospitalID, role, models.MembershipSourceAdmin); err != nil {
+		return nil, err
+	}
+
 	return staff, nil
 }
 
-func (s *StaffService) LoginByRequest(req *models.LoginRequest) (*models.UserStaff, error) {
+func (s *StaffService) LoginByRequest(req *models.LoginRequest, ip string) (*models.UserStaff, error) {
+	if err := s.guard.checkIP(req.Username, ip); err != nil {
+		return nil, err
+	}
+
 	var staff models.UserStaff
 	result := s.db.Where("username = ?", req.Username).First(&staff)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			// Unknown usernames go through the same lockout and the same
+			// password hashing as real accounts so that neither the status
+			// nor the timing tells them apart.
+			if err := s.guard.checkUsername(req.Username, ip); err != nil {
+				return nil, err
+			}
+			s.hasher.Verify(s.dummyPasswordHash(), req.Password)
+			s.guard.recordFailure(req.Username, nil, ip, models.LoginReasonUnknownUser)
 			return nil, fmt.Errorf("invalid credentials")
 		}
 		return nil, fmt.Errorf("database error: %v", result.Error)
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(staff.Password), []byte(req.Password))
-	if err != nil {
+	if err := s.guard.checkAccount(&staff, ip); err != nil {
+		return nil, err
+	}
+
+	// SSO accounts have no password and can only log in through the IdP.
+	ok := false
+	if staff.AuthProvider != models.AuthProviderOIDC {
+		var err error
+		ok, err = s.hasher.Verify(staff.Password, req.Password)
+		if err != nil {
+			log.Printf("Password verification error for staff %d: %v", staff.ID, err)
+		}
+	} else {
+		s.hasher.Verify(s.dummyPasswordHash(), req.Password)
+	}
+	if !ok {
+		s.guard.recordFailure(req.Username, &staff, ip, models.LoginReasonBadPassword)
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
+	// Checked after the password so the response does not reveal which
+	// usernames belong to deactivated accounts.
+	if !staff.IsActive {
+		s.guard.record(staff.Username, &staff.ID, ip, false, models.LoginReasonAccountInactive)
+		return nil, ErrStaffInactive
+	}
+
+	s.guard.recordSuccess(&staff, ip)
+	s.upgradePasswordHash(&staff, req.Password)
 	return &staff, nil
 }
+
+// dummyPasswordHash is a hash of a fixed password with the current
+// parameters, verified against when there is no real hash to check.
+func (s *StaffService) dummyPasswordHash() string {
+	s.dummyOnce.Do(func() {
+		hash, err := s.hasher.Hash("no account has this password")
+		if err != nil {
+			log.Printf("Failed to hash the dummy password: %v", err)
+		}
+		s.dummyHash = hash
+	})
+	return s.dummyHash
+}
+
+// upgradePasswordHash rehashes the password with the current algorithm and
+// parameters right after it was verified. Failure only means the old hash
+// stays in place until the next login.
+func (s *StaffService) upgradePasswordHash(staff *models.UserStaff, password string) {
+	if !s.hasher.NeedsRehash(staff.Password) {
+		return
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Printf("Failed to rehash password for staff %d: %v", staff.ID, err)
+		return
+	}
+
+	// Guard on the old hash so a concurrent password change is not overwritten.
+	result := s.db.Model(&models.UserStaff{}).
+		Where("id = ? AND password = ?", staff.ID, staff.Password).
+		Update("password", hashedPassword)
+	if result.Error != nil {
+		log.Printf("Failed to store upgraded password hash for staff %d: %v", staff.ID, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		staff.Password = hashedPassword
+		log.Printf("Upgraded password hash for staff %d", staff.ID)
+	}
+}
+
+func (s *StaffService) GetStaffByID(id uint) (*models.UserStaff, error) {
+	var staff models.UserStaff
+	if err := s.db.First(&staff, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStaffNotFound
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return &staff, nil
+}
+
+// UnlockStaff clears the lockout of a staff member in the admin's hospital.
+func (s *StaffService) UnlockStaff(hospitalID string, staffID, adminID uint) (*models.UserStaff, error) {
+	staff, err := s.GetStaffInHospital(hospitalID, staffID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Model(staff).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock staff: %v", err)
+	}
+
+	s.guard.record(staff.Username, &staff.ID, "", true, models.LoginReasonUnlockedByAdmin)
+	log.Printf("SECURITY: staff '%s' unlocked by admin %d", staff.Username, adminID)
+
+	staff.FailedLoginAttempts = 0
+	staff.LockedUntil = nil
+	return staff, nil
+}
+
+// ChangePassword replaces the staff member's password after checking the
+// current one and clears any pending forced change. Wrong current passwords
+// count towards the login lockout, so a stolen access token cannot be used
+// to guess the password.
+func (s *StaffService) ChangePassword(staffID uint, oldPassword, newPassword, ip string) error {
+	staff, err := s.GetStaffByID(staffID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.checkAccount(staff, ip); err != nil {
+		return err
+	}
+	if ok, _ := s.hasher.Verify(staff.Password, oldPassword); !ok {
+		s.guard.recordFailure(staff.Username, staff, ip, models.LoginReasonBadPassword)
+		return ErrInvalidCurrentPassword
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.setPassword(tx, staff, newPassword)
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("SECURITY: staff '%s' changed password", staff.Username)
+	return nil
+}
+
+// CreatePasswordReset issues a one-time reset token for a staff member in
+// the admin's hospital. The caller delivers the token out of band.
+func (s *StaffService) CreatePasswordReset(hospitalID string, staffID, adminID uint) (string, *models.UserStaff, error) {
+	staff, err := s.getManagedStaff(hospitalID, staffID)
+	if err != nil {
+		return "", nil, err
+	}
+	if staff.AuthProvider == models.AuthProviderOIDC {
+		return "", nil, ErrExternalAccount
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate reset token: %v", err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Only the newest reset token stays valid.
+		err := tx.Model(&models.PasswordResetToken{}).
+			Where("staff_id = ? AND used_at IS NULL", staff.ID).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PasswordResetToken{
+			TokenHash:   hashToken(token),
+			StaffID:     staff.ID,
+			CreatedByID: adminID,
+			ExpiresAt:   time.Now().Add(s.resetTTL),
+		}).Error
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create reset token: %v", err)
+	}
+
+	log.Printf("SECURITY: admin %d issued a password reset for staff '%s'", adminID, staff.Username)
+	return token, staff, nil
+}
+
+// ResetPassword consumes a reset token and sets the new password. A
+// successful reset also lifts any login lockout.
+func (s *StaffService) ResetPassword(token, newPassword string) (*models.UserStaff, error) {
+	var staff *models.UserStaff
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).
+			First(&reset).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetToken
+			}
+			return fmt.Errorf("database error: %v", err)
+		}
+
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("database error: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		var found models.UserStaff
+		if err := tx.First(&found, reset.StaffID).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+		staff = &found
+
+		if err := s.setPassword(tx, staff, newPassword); err != nil {
+			return err
+		}
+		return tx.Model(staff).Updates(map[string]interface{}{
+			"failed_login_attempts": 0,
+			"locked_until":          nil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("SECURITY: staff '%s' reset password", staff.Username)
+	return staff, nil
+}
+
+// setPassword enforces the password policy, including reuse of the current
+// and the last HistorySize passwords, and stores the new hash.
+func (s *StaffService) setPassword(tx *gorm.DB, staff *models.UserStaff, newPassword string) error {
+	if staff.AuthProvider == models.AuthProviderOIDC {
+		return ErrExternalAccount
+	}
+	if err := s.policy.Validate(newPassword, staff.Username); err != nil {
+		return err
+	}
+
+	if ok, _ := s.hasher.Verify(staff.Password, newPassword); ok {
+		return ErrPasswordReused
+	}
+
+	var history []models.StaffPasswordHistory
+	if s.policy.HistorySize > 0 {
+		err := tx.Where("staff_id = ?", staff.ID).Order("created_at DESC").Limit(s.policy.HistorySize).Find(&history).Error
+		if err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+	}
+	for _, h := range history {
+		if ok, _ := s.hasher.Verify(h.Hash, newPassword); ok {
+			return ErrPasswordReused
+		}
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if s.policy.HistorySize > 0 {
+		if err := tx.Create(&models.StaffPasswordHistory{StaffID: staff.ID, Hash: staff.Password}).Error; err != nil {
+			return fmt.Errorf("failed to store password history: %v", err)
+		}
+		// Keep only the newest HistorySize entries.
+		err := tx.Where("staff_id = ? AND id NOT IN (?)", staff.ID,
+			tx.Model(&models.StaffPasswordHistory{}).Select("id").Where("staff_id = ?", staff.ID).
+				Order("created_at DESC").Limit(s.policy.HistorySize),
+		).Delete(&models.StaffPasswordHistory{}).Error
+		if err != nil {
+			return fmt.Errorf("failed to trim password history: %v", err)
+		}
+	}
+
+	now := time.Now()
+	err = tx.Model(staff).Updates(map[string]interface{}{
+		"password":             hashedPassword,
+		"must_change_password": false,
+		"password_changed_at":  now,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update password: %v", err)
+	}
+
+	staff.Password = hashedPassword
+	staff.MustChangePassword = false
+	staff.PasswordChangedAt = &now
+	return nil
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// pgUniqueViolation is the PostgreSQL SQLSTATE of a unique constraint
+// violation.
+const pgUniqueViolation = "23505"
+
+const (
+	defaultStaffPageSize = 20
+	maxStaffPageSize     = 100
+)
+
+var (
+	ErrStaffInactive    = errors.New("staff account is deactivated")
+	ErrUsernameTaken    = errors.New("username is already taken")
+	ErrCannotModifySelf = errors.New("admins cannot deactivate, delete or change the role of their own account")
+)
+
+// GetStaffInHospital loads a staff member only if they are a member of
+// hospitalID, so admins cannot reach staff of other hospitals. The returned
+// HospitalID and Role are those of the membership.
+func (s *StaffService) GetStaffInHospital(hospitalID string, staffID uint) (*models.UserStaff, error) {
+	staff, err := s.GetStaffByID(staffID)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped, err := scopeToHospital(s.db, staff, hospitalID)
+	if errors.Is(err, ErrNotHospitalMember) {
+		return nil, ErrStaffNotFound
+	}
+	return scoped, err
+}
+
+// getManagedStaff loads a staff member whose home hospital is hospitalID.
+// Account-wide changes are reserved for the home hospital's admins.
+func (s *StaffService) getManagedStaff(hospitalID string, staffID uint) (*models.UserStaff, error) {
+	staff, err := s.GetStaffByID(staffID)
+	if err != nil {
+		return nil, err
+	}
+	if staff.HospitalID == hospitalID {
+		return staff, nil
+	}
+
+	if _, err := scopeToHospital(s.db, staff, hospitalID); err != nil {
+		if errors.Is(err, ErrNotHospitalMember) {
+			return nil, ErrStaffNotFound
+		}
+		return nil, err
+	}
+	return nil, ErrNotHomeHospital
+}
+
+// ListStaff returns one page of the staff of hospitalID together with the
+// total number of matches.
+func (s *StaffService) ListStaff(hospitalID string, query *models.StaffListQuery) ([]models.UserStaff, int64, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = defaultStaffPageSize
+	}
+	if query.PageSize > maxStaffPageSize {
+		query.PageSize = maxStaffPageSize
+	}
+
+	db := s.db.Model(&models.UserStaff{}).
+		Joins("JOIN staff_hospital_memberships m ON m.staff_id = user_staffs.id AND m.hospital_id = ? AND m.status = ?",
+			hospitalID, models.MembershipActive)
+	if query.Username != "" {
+		db = db.Where("user_staffs.username ILIKE ?", "%"+escapeLike(query.Username)+"%")
+	}
+	if query.Role != "" {
+		db = db.Where("m.role = ?", query.Role)
+	}
+	if query.Active != nil {
+		db = db.Where("user_staffs.is_active = ?", *query.Active)
+	}
+	// Count and Find below must not share statement state.
+	db = db.Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query database: %v", err)
+	}
+
+	var staff []models.UserStaff
+	err := db.Select("user_staffs.*").
+		Order("user_staffs.username ASC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&staff).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query database: %v", err)
+	}
+
+	// Report each staff member with their role in this hospital.
+	ids := make([]uint, len(staff))
+	for i := range staff {
+		ids[i] = staff[i].ID
+	}
+	var memberships []models.StaffHospitalMembership
+	if err := s.db.Where("hospital_id = ? AND staff_id IN ?", hospitalID, ids).Find(&memberships).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query database: %v", err)
+	}
+	roles := make(map[uint]models.Role, len(memberships))
+	for _, m := range memberships {
+		roles[m.StaffID] = m.Role
+	}
+	for i := range staff {
+		staff[i].HospitalID = hospitalID
+		staff[i].Role = roles[staff[i].ID]
+	}
+
+	return staff, total, nil
+}
+
+// UpdateStaff changes the role of a staff member in the admin's hospital
+// and, for the home hospital only, the username. A new role takes effect
+// with the next token refresh.
+func (s *StaffService) UpdateStaff(hospitalID string, staffID, adminID uint, req *models.UpdateStaffRequest) (*models.UserStaff, error) {
+	staff, err := s.GetStaffByID(staffID)
+	if err != nil {
+		return nil, err
+	}
+	home := staff.HospitalID == hospitalID
+
+	scoped, err := scopeToHospital(s.db, staff, hospitalID)
+	if err != nil {
+		if errors.Is(err, ErrNotHospitalMember) {
+			return nil, ErrStaffNotFound
+		}
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Username != nil && *req.Username != staff.Username {
+		if !home {
+			return nil, ErrNotHomeHospital
+		}
+		var count int64
+		if err := s.db.Unscoped().Model(&models.UserStaff{}).Where("username = ?", *req.Username).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+		if count > 0 {
+			return nil, ErrUsernameTaken
+		}
+		updates["username"] = *req.Username
+	}
+	roleChanged := req.Role != nil && *req.Role != scoped.Role
+	if roleChanged {
+		if staffID == adminID {
+			return nil, ErrCannotModifySelf
+		}
+		if home {
+			updates["role"] = *req.Role
+		}
+	}
+
+	if len(updates) == 0 && !roleChanged {
+		return scoped, nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if roleChanged {
+			err := tx.Model(&models.StaffHospitalMembership{}).
+				Where("staff_id = ? AND hospital_id = ?", staffID, hospitalID).
+				Update("role", *req.Role).Error
+			if err != nil {
+				return fmt.Errorf("failed to update membership: %v", err)
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		if err := tx.Model(staff).Updates(updates).Error; err != nil {
+			// The username check above cannot stop a concurrent rename.
+			if isUniqueViolation(err) {
+				return ErrUsernameTaken
+			}
+			return fmt.Errorf("failed to update staff: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changed := mapKeys(updates)
+	if roleChanged && !home {
+		changed = append(changed, "role")
+	}
+	log.Printf("SECURITY: admin %d updated staff %d in hospital %s: %v", adminID, staff.ID, hospitalID, changed)
+	return s.GetStaffInHospital(hospitalID, staffID)
+}
+
+// SetStaffActive deactivates or reactivates a staff member in the admin's
+// hospital. Deactivation revokes every refresh token; outstanding access
+// tokens are rejected by TokenService.ValidateAccessToken.
+func (s *StaffService) SetStaffActive(hospitalID string, staffID, adminID uint, active bool) (*models.UserStaff, error) {
+	if !active && staffID == adminID {
+		return nil, ErrCannotModifySelf
+	}
+
+	staff, err := s.getManagedStaff(hospitalID, staffID)
+	if err != nil {
+		return nil, err
+	}
+	if staff.IsActive == active {
+		return staff, nil
+	}
+
+	var deactivatedAt *time.Time
+	if !active {
+		now := time.Now()
+		deactivatedAt = &now
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(staff).Updates(map[string]interface{}{
+			"is_active":      active,
+			"deactivated_at": deactivatedAt,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update staff: %v", err)
+		}
+		if active {
+			return nil
+		}
+		return revokeStaffRefreshTokens(tx, staff.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if active {
+		log.Printf("SECURITY: staff '%s' reactivated by admin %d", staff.Username, adminID)
+	} else {
+		log.Printf("SECURITY: staff '%s' deactivated by admin %d", staff.Username, adminID)
+	}
+
+	staff.IsActive = active
+	staff.DeactivatedAt = deactivatedAt
+	return staff, nil
+}
+
+// DeleteStaff soft-deletes a staff member in the admin's hospital. The
+// username is released so it can be given to a new account.
+func (s *StaffService) DeleteStaff(hospitalID string, staffID, adminID uint) error {
+	if staffID == adminID {
+		return ErrCannotModifySelf
+	}
+
+	staff, err := s.getManagedStaff(hospitalID, staffID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(staff).Updates(map[string]interface{}{
+			"username":  fmt.Sprintf("%s#deleted-%d", staff.Username, staff.ID),
+			"is_active": false,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update staff: %v", err)
+		}
+		if err := revokeStaffRefreshTokens(tx, staff.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("staff_id = ?", staff.ID).Delete(&models.StaffHospitalMembership{}).Error; err != nil {
+			return fmt.Errorf("failed to remove memberships: %v", err)
+		}
+		if err := tx.Delete(staff).Error; err != nil {
+			return fmt.Errorf("failed to delete staff: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("SECURITY: staff '%s' deleted by admin %d", staff.Username, adminID)
+	return nil
+}
+
+func revokeStaffRefreshTokens(db *gorm.DB, staffID uint) error {
+	err := db.Model(&models.RefreshToken{}).
+		Where("staff_id = ? AND revoked_at IS NULL", staffID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+	}
+	return revokeSessions(db, "staff_id = ?", staffID)
+}
+
+// isUniqueViolation reports whether err comes from a unique constraint,
+// whether or not GORM translated it.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Is(err, gorm.ErrDuplicatedKey) || (errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation)
+}
+
+// escapeLike escapes the LIKE wildcards in a user supplied pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func mapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
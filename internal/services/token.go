@@ -0,0 +1,427 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+)
+
+type TokenService struct {
+	db     *gorm.DB
+	issuer *TokenIssuer
+}
+
+func NewTokenService(db *gorm.DB, issuer *TokenIssuer) *TokenService {
+	return &TokenService{db: db, issuer: issuer}
+}
+
+// ValidateAccessToken verifies the token signature and claims and rejects
+// tokens whose jti has been revoked. Tokens carrying a purpose are only
+// accepted when that purpose is listed in allowedPurposes.
+func (s *TokenService) ValidateAccessToken(tokenString string, allowedPurposes ...string) (*models.JWTClaims, error) {
+	claims, err := s.issuer.ValidateJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != "" && !containsString(allowedPurposes, claims.Purpose) {
+		return nil, fmt.Errorf("%w: token purpose %q not allowed here", ErrInvalidToken, claims.Purpose)
+	}
+
+	revoked, err := s.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	// Deactivated or deleted staff lose access before their tokens expire.
+	var active int64
+	err = s.db.Model(&models.UserStaff{}).Where("id = ? AND is_active = ?", claims.StaffID, true).Count(&active).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	if active == 0 {
+		return nil, ErrStaffInactive
+	}
+
+	if claims.SessionID != "" {
+		if err := s.touchSession(claims.SessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+// touchSession rejects tokens of revoked sessions and keeps last_seen_at
+// roughly up to date.
+func (s *TokenService) touchSession(sessionID string) error {
+	var session models.StaffSession
+	if err := s.db.Select("id", "revoked_at", "last_seen_at").Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return fmt.Errorf("database error: %v", err)
+	}
+	if session.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) > lastSeenResolution {
+		if err := s.db.Model(&session).Update("last_seen_at", now).Error; err != nil {
+			log.Printf("Failed to update last seen of session %s: %v", session.ID, err)
+		}
+	}
+	return nil
+}
+
+// IssueTokenPair starts a new session, i.e. a new refresh token family, for
+// the staff member on the client's device and returns its refresh token
+// together with a fresh access token. Both are scoped to staff.HospitalID;
+// see MembershipService.ScopeToHospital.
+func (s *TokenService) IssueTokenPair(staff *models.UserStaff, client ClientInfo) (*models.LoginResponse, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token family: %v", err)
+	}
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %v", err)
+	}
+
+	var refreshToken string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		session := &models.StaffSession{
+			ID:         sessionID,
+			StaffID:    staff.ID,
+			HospitalID: staff.HospitalID,
+			FamilyID:   familyID,
+			IPAddress:  client.IPAddress,
+			UserAgent:  truncateUserAgent(client.UserAgent),
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(s.issuer.RefreshTTL()),
+		}
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("failed to create session: %v", err)
+		}
+
+		refreshToken, _, err = s.createRefreshToken(tx, staff, familyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.buildResponse(staff, refreshToken, sessionID)
+}
+
+// IssueChallenge returns a login response carrying only a short-lived token
+// for purpose, used when the login needs another step before a full token
+// pair may be issued.
+func (s *TokenService) IssueChallenge(staff *models.UserStaff, purpose string) (*models.LoginResponse, error) {
+	token, err := s.issuer.GenerateChallengeToken(int(staff.ID), staff.HospitalID, staff.Role, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.LoginResponse{}
+	switch purpose {
+	case models.TokenPurposeMFA:
+		response.MFARequired = true
+		response.MFAToken = token
+	case models.TokenPurposeMFAEnroll:
+		response.MFAEnrollmentRequired = true
+		response.MFAToken = token
+	case models.TokenPurposePasswordChange:
+		response.PasswordChangeRequired = true
+		response.PasswordChangeToken = token
+	default:
+		return nil, fmt.Errorf("unknown challenge purpose %q", purpose)
+	}
+	return response, nil
+}
+
+// Refresh rotates refreshToken: the presented token is revoked and replaced
+// by a new one in the same family. Presenting a token that was already
+// rotated is treated as theft and revokes the entire family. The session of
+// the family is extended and its device details updated from client.
+func (s *TokenService) Refresh(refreshToken string, client ClientInfo) (*models.LoginResponse, error) {
+	var current models.RefreshToken
+	err := s.db.Where("token_hash = ?", hashToken(refreshToken)).First(&current).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	now := time.Now()
+	if current.RevokedAt != nil {
+		log.Printf("Refresh token reuse detected for staff %d, revoking family %s", current.StaffID, current.FamilyID)
+		if err := s.revokeFamily(current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if now.After(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var staff models.UserStaff
+	if err := s.db.First(&staff, current.StaffID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	if !staff.IsActive {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// The role may have changed, or the membership gone, since the family
+	// was started.
+	scoped, err := scopeToHospital(s.db, &staff, current.HospitalID)
+	if err != nil {
+		if errors.Is(err, ErrNotHospitalMember) {
+			if err := s.revokeFamily(current.FamilyID); err != nil {
+				return nil, err
+			}
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	var session models.StaffSession
+	err = s.db.Where("family_id = ?", current.FamilyID).First(&session).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	if session.RevokedAt != nil {
+		if err := s.revokeFamily(current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var newToken string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		token, replacement, err := s.createRefreshToken(tx, scoped, current.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		if session.ID == "" {
+			// Families started before sessions were tracked get one now.
+			sessionID, err := randomToken(16)
+			if err != nil {
+				return fmt.Errorf("failed to generate session ID: %v", err)
+			}
+			session = models.StaffSession{
+				ID:         sessionID,
+				StaffID:    scoped.ID,
+				HospitalID: scoped.HospitalID,
+				FamilyID:   current.FamilyID,
+				CreatedAt:  current.CreatedAt,
+			}
+		}
+		session.IPAddress = client.IPAddress
+		session.UserAgent = truncateUserAgent(client.UserAgent)
+		session.LastSeenAt = now
+		session.ExpiresAt = replacement.ExpiresAt
+		if err := tx.Save(&session).Error; err != nil {
+			return fmt.Errorf("failed to update session: %v", err)
+		}
+
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Updates(map[string]interface{}{"revoked_at": now, "replaced_by_id": replacement.ID})
+		if result.Error != nil {
+			return fmt.Errorf("failed to rotate refresh token: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidRefreshToken
+		}
+
+		newToken = token
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.buildResponse(scoped, newToken, session.ID)
+}
+
+// Logout revokes the access token described by claims together with its
+// session and, when given, the refresh token family it was issued with.
+func (s *TokenService) Logout(claims *models.JWTClaims, refreshToken string) error {
+	if err := s.RevokeAccessToken(claims); err != nil {
+		return err
+	}
+	if claims.SessionID != "" {
+		if err := revokeSessions(s.db, "id = ?", claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	var token models.RefreshToken
+	err := s.db.Where("token_hash = ? AND staff_id = ?", hashToken(refreshToken), claims.StaffID).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	return s.revokeFamily(token.FamilyID)
+}
+
+// RevokeAccessToken blocks the access token until its natural expiry.
+func (s *TokenService) RevokeAccessToken(claims *models.JWTClaims) error {
+	if claims.ID == "" {
+		return fmt.Errorf("token has no jti")
+	}
+
+	expiresAt := time.Now().Add(s.issuer.AccessTTL())
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	revoked := models.RevokedToken{
+		JTI:       claims.ID,
+		StaffID:   uint(claims.StaffID),
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	}
+	if err := s.db.Where(models.RevokedToken{JTI: claims.ID}).FirstOrCreate(&revoked).Error; err != nil {
+		return fmt.Errorf("failed to revoke token: %v", err)
+	}
+	return nil
+}
+
+// RevokeAllRefreshTokens revokes every outstanding refresh token of a staff
+// member so that no new access tokens can be obtained.
+func (s *TokenService) RevokeAllRefreshTokens(staffID uint) error {
+	return revokeStaffRefreshTokens(s.db, staffID)
+}
+
+// ValidateChallengeToken accepts only tokens issued for purpose.
+func (s *TokenService) ValidateChallengeToken(tokenString, purpose string) (*models.JWTClaims, error) {
+	claims, err := s.ValidateAccessToken(tokenString, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("%w: expected a %q token", ErrInvalidToken, purpose)
+	}
+	return claims, nil
+}
+
+func (s *TokenService) IsRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return true, nil
+	}
+
+	var count int64
+	if err := s.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("database error: %v", err)
+	}
+	return count > 0, nil
+}
+
+func (s *TokenService) revokeFamily(familyID string) error {
+	now := time.Now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+		}
+
+		err = tx.Model(&models.StaffSession{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return fmt.Errorf("failed to revoke session: %v", err)
+		}
+		return nil
+	})
+}
+
+func (s *TokenService) createRefreshToken(db *gorm.DB, staff *models.UserStaff, familyID string) (string, *models.RefreshToken, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+
+	record := &models.RefreshToken{
+		TokenHash:  hashToken(token),
+		FamilyID:   familyID,
+		StaffID:    staff.ID,
+		HospitalID: staff.HospitalID,
+		ExpiresAt:  time.Now().Add(s.issuer.RefreshTTL()),
+	}
+	if err := db.Create(record).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to store refresh token: %v", err)
+	}
+
+	return token, record, nil
+}
+
+func (s *TokenService) buildResponse(staff *models.UserStaff, refreshToken, sessionID string) (*models.LoginResponse, error) {
+	accessToken, err := s.issuer.GenerateJWT(int(staff.ID), staff.HospitalID, staff.Role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.issuer.AccessTTL().Seconds()),
+	}, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"errors"
+	"hospital-api/internal/configs"
+	"hospital-api/internal/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// testTokenConfig is a development configuration that signs with HS256.
+func testTokenConfig() configs.Config {
+	return configs.Config{
+		AppEnv:                        "development",
+		JWTSecret:                     "test-secret",
+		JWTExpirationInSeconds:        900,
+		JWTRefreshExpirationInSeconds: 3600,
+		JWTIssuer:                     "hospital-api",
+		JWTAudience:                   "hospital-api",
+	}
+}
+
+func newTestTokenService(t *testing.T, db *gorm.DB) *TokenService {
+	t.Helper()
+	issuer, err := NewTokenIssuer(testTokenConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTokenService(db, issuer)
+}
+
+// createTestStaff creates an active staff member with a home membership in
+// hospitalID.
+func createTestStaff(t *testing.T, db *gorm.DB, username, hospitalID string, role models.Role) *models.UserStaff {
+	t.Helper()
+	staff := &models.UserStaff{Username: username, Password: "unused", Role: role, HospitalID: hospitalID}
+	if err := db.Create(staff).Error; err != nil {
+		t.Fatalf("create staff %s: %v", username, err)
+	}
+	if _, err := createMembership(db, staff.ID, hospitalID, role, models.MembershipSourceAdmin); err != nil {
+		t.Fatal(err)
+	}
+	return staff
+}
+
+func refreshTokenRecord(t *testing.T, db *gorm.DB, token string) models.RefreshToken {
+	t.Helper()
+	var record models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+		t.Fatalf("find refresh token: %v", err)
+	}
+	return record
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "TOK1")
+	s := newTestTokenService(t, db)
+	staff := createTestStaff(t, db, "token.rotate", "TOK1", models.RoleDoctor)
+
+	pair, err := s.IssueTokenPair(staff, ClientInfo{IPAddress: "10.0.0.1", UserAgent: "first"})
+	if err != nil {
+		t.Fatalf("IssueTokenPair: %v", err)
+	}
+	rotated, err := s.Refresh(pair.RefreshToken, ClientInfo{IPAddress: "10.0.0.2", UserAgent: "second"})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if rotated.RefreshToken == pair.RefreshToken {
+		t.Fatal("Refresh returned the presented refresh token")
+	}
+
+	old := refreshTokenRecord(t, db, pair.RefreshToken)
+	replacement := refreshTokenRecord(t, db, rotated.RefreshToken)
+	if old.RevokedAt == nil || old.ReplacedByID == nil || *old.ReplacedByID != replacement.ID {
+		t.Errorf("rotated token: revoked %v, replaced by %v, want revoked and replaced by %d", old.RevokedAt, old.ReplacedByID, replacement.ID)
+	}
+	if replacement.FamilyID != old.FamilyID || replacement.RevokedAt != nil {
+		t.Errorf("replacement: family %s, revoked %v; want family %s, not revoked", replacement.FamilyID, replacement.RevokedAt, old.FamilyID)
+	}
+
+	var session models.StaffSession
+	if err := db.Where("family_id = ?", old.FamilyID).First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if session.IPAddress != "10.0.0.2" || session.UserAgent != "second" || session.RevokedAt != nil {
+		t.Errorf("session after refresh = %+v", session)
+	}
+
+	claims, err := s.ValidateAccessToken(rotated.Token)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+	if claims.SessionID != session.ID || claims.HospitalID != "TOK1" || claims.Role != models.RoleDoctor {
+		t.Errorf("access token claims = %+v", claims)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	tests := []struct {
+		name   string
+		replay int // index of the rotated token that is presented again
+	}{
+		{"first token", 0},
+		{"token in the middle", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			createTestHospitals(t, db, "TOK1")
+			s := newTestTokenService(t, db)
+			staff := createTestStaff(t, db, "token.reuse", "TOK1", models.RoleNurse)
+
+			pair, err := s.IssueTokenPair(staff, ClientInfo{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens := []string{pair.RefreshToken}
+			accessTokens := []string{pair.Token}
+			for i := 0; i < 2; i++ {
+				next, err := s.Refresh(tokens[i], ClientInfo{})
+				if err != nil {
+					t.Fatalf("Refresh %d: %v", i, err)
+				}
+				tokens = append(tokens, next.RefreshToken)
+				accessTokens = append(accessTokens, next.Token)
+			}
+
+			if _, err := s.Refresh(tokens[tt.replay], ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Fatalf("replayed Refresh = %v, want ErrInvalidRefreshToken", err)
+			}
+
+			// The legitimate holder's latest token dies with the family.
+			latest := tokens[len(tokens)-1]
+			if _, err := s.Refresh(latest, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("Refresh of the latest token after reuse = %v, want ErrInvalidRefreshToken", err)
+			}
+
+			family := refreshTokenRecord(t, db, latest).FamilyID
+			var live int64
+			db.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", family).Count(&live)
+			if live != 0 {
+				t.Errorf("%d refresh tokens of the family are still live", live)
+			}
+			var session models.StaffSession
+			if err := db.Where("family_id = ?", family).First(&session).Error; err != nil {
+				t.Fatal(err)
+			}
+			if session.RevokedAt == nil {
+				t.Error("session of the reused family was not revoked")
+			}
+			if _, err := s.ValidateAccessToken(accessTokens[len(accessTokens)-1]); !errors.Is(err, ErrTokenRevoked) {
+				t.Errorf("access token of the revoked session = %v, want ErrTokenRevoked", err)
+			}
+		})
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	tests := []struct {
+		name          string
+		prepare       func(t *testing.T, db *gorm.DB, staff *models.UserStaff, token models.RefreshToken)
+		revokesFamily bool
+	}{
+		{
+			name: "expired token",
+			prepare: func(t *testing.T, db *gorm.DB, staff *models.UserStaff, token models.RefreshToken) {
+				db.Model(&token).Update("expires_at", time.Now().Add(-time.Minute))
+			},
+		},
+		{
+			name: "deactivated staff",
+			prepare: func(t *testing.T, db *gorm.DB, staff *models.UserStaff, token models.RefreshToken) {
+				db.Model(staff).Update("is_active", false)
+			},
+		},
+		{
+			name: "membership removed",
+			prepare: func(t *testing.T, db *gorm.DB, staff *models.UserStaff, token models.RefreshToken) {
+				db.Where("staff_id = ?", staff.ID).Delete(&models.StaffHospitalMembership{})
+			},
+			revokesFamily: true,
+		},
+		{
+			name: "session revoked",
+			prepare: func(t *testing.T, db *gorm.DB, staff *models.UserStaff, token models.RefreshToken) {
+				db.Model(&models.StaffSession{}).Where("family_id = ?", token.FamilyID).Update("revoked_at", time.Now())
+			},
+			revokesFamily: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			createTestHospitals(t, db, "TOK1")
+			s := newTestTokenService(t, db)
+			staff := createTestStaff(t, db, "token.reject", "TOK1", models.RoleClerk)
+
+			pair, err := s.IssueTokenPair(staff, ClientInfo{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := refreshTokenRecord(t, db, pair.RefreshToken)
+			tt.prepare(t, db, staff, token)
+
+			if _, err := s.Refresh(pair.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Fatalf("Refresh = %v, want ErrInvalidRefreshToken", err)
+			}
+			revoked := refreshTokenRecord(t, db, pair.RefreshToken).RevokedAt != nil
+			if revoked != tt.revokesFamily {
+				t.Errorf("token revoked = %v, want %v", revoked, tt.revokesFamily)
+			}
+		})
+	}
+
+	t.Run("unknown token", func(t *testing.T) {
+		s := newTestTokenService(t, openTestDB(t))
+		if _, err := s.Refresh("never-issued", ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("Refresh = %v, want ErrInvalidRefreshToken", err)
+		}
+	})
+}
+
+func TestLogoutRevokesSessionAndFamily(t *testing.T) {
+	db := openTestDB(t)
+	createTestHospitals(t, db, "TOK1")
+	s := newTestTokenService(t, db)
+	staff := createTestStaff(t, db, "token.logout", "TOK1", models.RoleDoctor)
+
+	pair, err := s.IssueTokenPair(staff, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := s.ValidateAccessToken(pair.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Logout(claims, pair.RefreshToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	if _, err := s.ValidateAccessToken(pair.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("access token after logout = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := s.Refresh(pair.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logout = %v, want ErrInvalidRefreshToken", err)
+	}
+}
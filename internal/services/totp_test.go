@@ -0,0 +1,94 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key of RFC 6238 appendix B,
+// "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC lists 8-digit codes; these are their last six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := totpCode(rfc6238Secret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("totpCode at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPCodeAcceptsLowerCaseSecret(t *testing.T) {
+	got, err := totpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 59/totpPeriod)
+	if err != nil || got != "287082" {
+		t.Errorf("totpCode = %q, %v; want 287082", got, err)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / totpPeriod
+	code := func(offset int64) string {
+		c, err := totpCode(rfc6238Secret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(0), 0, step, true},
+		{"previous step within skew", code(-1), 0, step - 1, true},
+		{"next step within skew", code(1), 0, step + 1, true},
+		{"two steps behind", code(-2), 0, 0, false},
+		{"two steps ahead", code(2), 0, 0, false},
+		{"surrounding spaces", " " + code(0) + " ", 0, step, true},
+		{"replay of the last used step", code(0), step, 0, false},
+		{"earlier step than the last used", code(-1), step, 0, false},
+		{"newer step than the last used", code(1), step, step + 1, true},
+		{"too short", code(0)[:5], 0, 0, false},
+		{"too long", code(0) + "0", 0, 0, false},
+		{"wrong digit", wrongDigit(code(0)), 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := validateTOTP(rfc6238Secret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("validateTOTP(%q) = %d, %v; want %d, %v", tt.code, gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+// wrongDigit changes the last digit of code.
+func wrongDigit(code string) string {
+	last := code[len(code)-1]
+	return code[:len(code)-1] + string('0'+(last-'0'+1)%10)
+}
+
+func TestValidateTOTPInvalidSecret(t *testing.T) {
+	if _, ok := validateTOTP("not base32!", "123456", time.Now(), 0); ok {
+		t.Error("validateTOTP accepted a code for an invalid secret")
+	}
+}
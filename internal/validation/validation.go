@@ -0,0 +1,203 @@
+// Package validation holds the identifier rules shared by request binding
+// and the services, and turns binding failures into field-level errors.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"hospital-api/internal/models"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	// ICAO 9303 document numbers are at most nine letters and digits.
+	passportPattern = regexp.MustCompile(`^[A-Z0-9]{6,9}$`)
+	// hnPattern is the format every hospital accepts; hospitals may narrow
+	// it further with their own pattern.
+	hnPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9/-]{0,19}$`)
+	// Thai mobile numbers start with 06, 08 or 09 and have ten digits.
+	thaiMobilePattern = regexp.MustCompile(`^0[689][0-9]{8}$`)
+)
+
+// Errors lists the fields of a request that failed validation.
+type Errors []models.FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, f := range e {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return "invalid input: " + strings.Join(parts, "; ")
+}
+
+// Register adds the custom rules to Gin's binding engine and makes
+// validation errors report JSON field names. Call it before serving.
+func Register() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected binding validator engine")
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name := strings.Split(field.Tag.Get(tag), ",")[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	rules := map[string]func(string) bool{
+		"national_id":     IsNationalID,
+		"thai_citizen_id": IsThaiCitizenID,
+		"alien_id":        IsAlienID,
+		"passport":        IsPassportNumber,
+		"hn":              IsHN,
+		"thai_mobile":     IsThaiMobile,
+	}
+	for tag, rule := range rules {
+		rule := rule
+		err := v.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return rule(fl.Field().String())
+		})
+		if err != nil {
+			return fmt.Errorf("failed to register %s validator: %v", tag, err)
+		}
+	}
+	return nil
+}
+
+// ValidThaiIDChecksum reports whether id is 13 digits whose last digit is
+// the mod-11 check digit of the first twelve.
+func ValidThaiIDChecksum(id string) bool {
+	if len(id) != 13 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return false
+		}
+		if i < 12 {
+			sum += int(id[i]-'0') * (13 - i)
+		}
+	}
+	return int(id[12]-'0') == (11-sum%11)%10
+}
+
+// IsThaiCitizenID accepts IDs of the Thai citizen categories 1-5 and 8.
+func IsThaiCitizenID(id string) bool {
+	return ValidThaiIDChecksum(id) && strings.ContainsRune("123458", rune(id[0]))
+}
+
+// IsAlienID accepts IDs issued to non-Thai residents: category 6
+// (temporary residents), 7 (their children born in Thailand) and 0
+// (persons without Thai nationality status).
+func IsAlienID(id string) bool {
+	return ValidThaiIDChecksum(id) && strings.ContainsRune("067", rune(id[0]))
+}
+
+// IsNationalID accepts any 13-digit ID issued by the Thai civil registry.
+func IsNationalID(id string) bool {
+	return IsThaiCitizenID(id) || IsAlienID(id)
+}
+
+func IsPassportNumber(passport string) bool {
+	return passportPattern.MatchString(NormalizePassport(passport))
+}
+
+func IsHN(hn string) bool {
+	return hnPattern.MatchString(hn)
+}
+
+func IsThaiMobile(phone string) bool {
+	return thaiMobilePattern.MatchString(NormalizeThaiMobile(phone))
+}
+
+// NormalizePassport upper-cases a passport number and drops spaces.
+func NormalizePassport(passport string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(passport), " ", ""))
+}
+
+// NormalizeThaiMobile strips separators and turns the +66 country code into
+// the domestic leading zero, e.g. "+66 81-234-5678" becomes "0812345678".
+func NormalizeThaiMobile(phone string) string {
+	phone = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(strings.TrimSpace(phone))
+	if strings.HasPrefix(phone, "+66") {
+		phone = "0" + phone[3:]
+	} else if strings.HasPrefix(phone, "66") && len(phone) == 11 {
+		phone = "0" + phone[2:]
+	}
+	return phone
+}
+
+// FieldErrors converts the validator errors in err into field errors. It
+// returns nil when err is not a validation failure, e.g. malformed JSON.
+func FieldErrors(err error) Errors {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+
+	fields := make(Errors, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fields = append(fields, models.FieldError{Field: fe.Field(), Message: message(fe)})
+	}
+	return fields
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "national_id":
+		return "must be a valid 13-digit Thai national ID"
+	case "thai_citizen_id":
+		return "must be a valid Thai citizen ID"
+	case "alien_id":
+		return "must be a valid non-Thai (alien) ID"
+	case "passport":
+		return "must be a passport number of 6-9 letters and digits"
+	case "hn":
+		return "must be a hospital number of up to 20 letters, digits, '-' or '/'"
+	case "thai_mobile":
+		return "must be a Thai mobile number, e.g. 0812345678"
+	case "email":
+		return "must be a valid email address"
+	case "datetime":
+		return "must be a date in " + dateLayout(fe.Param()) + " format"
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "len":
+		return "must be exactly " + fe.Param() + unit(fe)
+	case "min":
+		return "must be at least " + fe.Param() + unit(fe)
+	case "max":
+		return "must be at most " + fe.Param() + unit(fe)
+	default:
+		return "failed the '" + fe.Tag() + "' rule"
+	}
+}
+
+func unit(fe validator.FieldError) string {
+	if fe.Kind() == reflect.String {
+		return " characters"
+	}
+	return ""
+}
+
+func dateLayout(layout string) string {
+	if layout == "2006-01-02" {
+		return "YYYY-MM-DD"
+	}
+	return layout
+}
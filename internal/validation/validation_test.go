@@ -0,0 +1,90 @@
+package validation
+
+import "testing"
+
+func TestValidThaiIDChecksum(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{"valid", "1234567890121", true},
+		{"valid check digit zero", "1101700203450", true},
+		{"wrong check digit", "1234567890122", false},
+		{"check digit from remainder one", "1101700203468", true},
+		{"too short", "123456789012", false},
+		{"too long", "12345678901211", false},
+		{"non-digit", "12345678901a1", false},
+		{"separators", "1-2345-67890-12-1", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidThaiIDChecksum(tt.id); got != tt.want {
+				t.Errorf("ValidThaiIDChecksum(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsNationalID(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		citizen    bool
+		alien      bool
+		nationalID bool
+	}{
+		{"citizen category 1", "1234567890121", true, false, true},
+		{"citizen category 3", "3101500123459", true, false, true},
+		{"citizen category 8", "8123456789011", true, false, true},
+		{"no nationality status", "0123456789016", false, true, true},
+		{"temporary resident", "6123456789015", false, true, true},
+		{"child of temporary resident", "7123456789013", false, true, true},
+		{"unused category 9", "9123456789010", false, false, false},
+		{"bad checksum", "1234567890122", false, false, false},
+		{"empty", "", false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsThaiCitizenID(tt.id); got != tt.citizen {
+				t.Errorf("IsThaiCitizenID(%q) = %v, want %v", tt.id, got, tt.citizen)
+			}
+			if got := IsAlienID(tt.id); got != tt.alien {
+				t.Errorf("IsAlienID(%q) = %v, want %v", tt.id, got, tt.alien)
+			}
+			if got := IsNationalID(tt.id); got != tt.nationalID {
+				t.Errorf("IsNationalID(%q) = %v, want %v", tt.id, got, tt.nationalID)
+			}
+		})
+	}
+}
+
+func TestNormalizeThaiMobile(t *testing.T) {
+	tests := []struct {
+		phone  string
+		want   string
+		mobile bool
+	}{
+		{"0812345678", "0812345678", true},
+		{"081-234-5678", "0812345678", true},
+		{" 081 234 5678 ", "0812345678", true},
+		{"(081) 234-5678", "0812345678", true},
+		{"+66812345678", "0812345678", true},
+		{"+66 81-234-5678", "0812345678", true},
+		{"66812345678", "0812345678", true},
+		{"6681234567", "6681234567", false},
+		{"021234567", "021234567", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			if got := NormalizeThaiMobile(tt.phone); got != tt.want {
+				t.Errorf("NormalizeThaiMobile(%q) = %q, want %q", tt.phone, got, tt.want)
+			}
+			if got := IsThaiMobile(tt.phone); got != tt.mobile {
+				t.Errorf("IsThaiMobile(%q) = %v, want %v", tt.phone, got, tt.mobile)
+			}
+		})
+	}
+}